@@ -1,168 +1,377 @@
 package addition
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
+	"future_today/internal/cerrors"
 	"future_today/internal/config"
-	"io"
-	"net/http"
+	"future_today/internal/normalize"
+	"slices"
+	"sort"
+	"sync"
 )
 
+// Enricher добавляет к имени возраст, пол и национальность
+type Enricher interface {
+	Enrich(ctx context.Context, q Query) (*Result, error)
+	EnrichBatch(ctx context.Context, qs []Query) (map[Query]*Result, error)
+}
+
+// Query имя и необязательная подсказка страны, к которой привязать
+// возраст и пол
+type Query struct {
+	Name        string
+	CountryHint string
+}
+
+const (
+	FieldAge         = "age"
+	FieldGender      = "gender"
+	FieldNationality = "nationality"
+)
+
+// Result ответ обогащения. В Missing попадают поля, провайдеры которых
+// недоступны (предохранитель разомкнут), такие поля остаются пустыми
+type Result struct {
+	// NormalizedName имя в том виде, в каком оно ушло провайдерам
+	NormalizedName         string
+	Age                    int
+	AgeCount               int
+	Gender                 string
+	GenderProbability      float64
+	Nationality            string
+	NationalityProbability float64
+	Countries              []CountryProbability
+	// Country страна, к которой привязаны возраст и пол, пустая - глобально
+	Country string
+	Missing []string
+	// Sources имя провайдера, ответившего за каждое полученное поле
+	Sources map[string]string
+}
+
+// Pending часть полей не получена и ждет дообогащения
+func (r *Result) Pending() bool {
+	return len(r.Missing) > 0
+}
+
+func (r *Result) Has(field string) bool {
+	return !slices.Contains(r.Missing, field)
+}
+
+// Addition обогащает в два этапа: сначала национальность, затем возраст
+// и пол с привязкой к стране (подсказка, найденная национальность или
+// страна по умолчанию). Если локальная выборка меньше minLocalCount,
+// берется глобальный ответ
 type Addition struct {
-	http.Client
-	agifyURL       string
-	nationalizeURL string
-	genderizeURL   string
-}
-
-// no async
-type AdditionResponse struct {
-	Age     int    `json:"age"`
-	Gender  string `json:"gender"`
-	Country []struct {
-		CountryID string `json:"country_id"`
-	} `json:"country"`
-}
-
-// async
-type AgeResponse struct {
-	Age int `json:"age"`
-}
-
-type GenderResponse struct {
-	Gender string `json:"gender"`
-}
-
-type NationResponse struct {
-	Country []struct {
-		CountryID string `json:"country_id"`
-	} `json:"country"`
-}
-
-func NewAddition(cfg *config.Config) *Addition {
-	return &Addition{agifyURL: cfg.AgifyURL, nationalizeURL: cfg.NationalizeURL, genderizeURL: cfg.GenderizeURL}
-}
-
-// добавить асинхронности
-// func (add *Addition) GetAddition(name string) (*AdditionResponse, error) {
-// 	var addResp AdditionResponse
-// 	resp_age, err := add.Get(add.agifyURL + "/?name=" + name)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error getting age by url:%v", err)
-// 	}
-// 	bytes, err := io.ReadAll(resp_age.Body)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error in unmarshalling:%v", err)
-// 	}
-// 	err = json.Unmarshal(bytes, &addResp)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error reading body:%v", err)
-// 	}
-// 	respGender, err := add.Get(add.genderizeURL + "/?name=" + name)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error getting gender by url:%v", err)
-// 	}
-// 	bytes, err = io.ReadAll(respGender.Body)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error in unmarshalling:%v", err)
-// 	}
-// 	err = json.Unmarshal(bytes, &addResp)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error reading body:%v", err)
-// 	}
-// 	respNation, err := add.Get(add.nationalizeURL + "/?name=" + name)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error getting nation by url:%v", err)
-// 	}
-// 	bytes, err = io.ReadAll(respNation.Body)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error in unmarshalling:%v", err)
-// 	}
-// 	err = json.Unmarshal(bytes, &addResp)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("error reading body:%v", err)
-// 	}
-
-// 	return &addResp, nil
-
-// }
-
-func (add *Addition) GetAdditionAsync(name string) (int, string, string, error) {
-	var ageResp AgeResponse
-	var genderResp GenderResponse
-	var natResp NationResponse
-
-	ageChan := make(chan int)
-	nationChan := make(chan string)
-	genderChan := make(chan string)
-	errChan := make(chan error)
+	age      AgeProvider
+	gender   GenderProvider
+	nation   NationalityProvider
+	breakers map[string]*Breaker
+	// имена провайдеров по полям, попадают в Result.Sources
+	names map[string]string
+	// офлайн-наборы данных по имени провайдера, для горячей перезагрузки
+	datasets map[string]*Dataset
 
-	go func() {
-		resp_age, err := add.Get(add.agifyURL + "/?name=" + name)
-		if err != nil {
-			errChan <- err
-			return
+	defaultCountry string
+	minLocalCount  int
+	normalizer     *normalize.Normalizer
+}
+
+func New(age AgeProvider, gender GenderProvider, nation NationalityProvider) *Addition {
+	return &Addition{age: age, gender: gender, nation: nation, breakers: map[string]*Breaker{}, names: map[string]string{}, datasets: map[string]*Dataset{}}
+}
+
+// NewAddition собирает Addition из провайдеров, выбранных в конфиге.
+// Каждый провайдер закрыт своим предохранителем, если cache не nil,
+// ответы провайдеров кэшируются
+func NewAddition(cfg *config.Config, cache *Cache) (*Addition, error) {
+	age, err := NewAgeProvider(cfg.AgeProvider, cfg)
+	if err != nil {
+		return nil, err
+	}
+	gender, err := NewGenderProvider(cfg.GenderProvider, cfg)
+	if err != nil {
+		return nil, err
+	}
+	nation, err := NewNationalityProvider(cfg.NationalityProvider, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	normalizer, err := normalize.New(cfg.Transliteration)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", cerrors.ErrInvalidConfig, err)
+	}
+
+	add := New(nil, nil, nil)
+	add.normalizer = normalizer
+	add.defaultCountry = cfg.DefaultCountry
+	add.minLocalCount = cfg.MinLocalCount
+	add.names[FieldAge] = cfg.AgeProvider
+	add.names[FieldGender] = cfg.GenderProvider
+	add.names[FieldNationality] = cfg.NationalityProvider
+	for provider, p := range map[string]any{cfg.AgeProvider: age, cfg.GenderProvider: gender, cfg.NationalityProvider: nation} {
+		if d, ok := p.(*Dataset); ok {
+			add.datasets[provider] = d
 		}
-		bytes, err := io.ReadAll(resp_age.Body)
-		if err != nil {
-			errChan <- err
-			return
+	}
+	add.age = &breakerAge{next: age, breaker: add.breaker(cfg, cfg.AgeProvider)}
+	add.gender = &breakerGender{next: gender, breaker: add.breaker(cfg, cfg.GenderProvider)}
+	add.nation = &breakerNationality{next: nation, breaker: add.breaker(cfg, cfg.NationalityProvider)}
+	if cache != nil {
+		add.age = &cachedAge{next: add.age, provider: cfg.AgeProvider, cache: cache}
+		add.gender = &cachedGender{next: add.gender, provider: cfg.GenderProvider, cache: cache}
+		add.nation = &cachedNationality{next: add.nation, provider: cfg.NationalityProvider, cache: cache}
+	}
+	return add, nil
+}
+
+// breaker один предохранитель на провайдер, даже если он отвечает за несколько полей
+func (add *Addition) breaker(cfg *config.Config, provider string) *Breaker {
+	b, ok := add.breakers[provider]
+	if !ok {
+		b = NewBreaker(provider, cfg.BreakerThreshold, cfg.BreakerOpenTimeout)
+		add.breakers[provider] = b
+	}
+	return b
+}
+
+// Breakers состояние предохранителей провайдеров
+func (add *Addition) Breakers() []BreakerStatus {
+	statuses := make([]BreakerStatus, 0, len(add.breakers))
+	for _, b := range add.breakers {
+		statuses = append(statuses, b.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Provider < statuses[j].Provider })
+	return statuses
+}
+
+// Datasets состояние офлайн-наборов данных по имени провайдера
+func (add *Addition) Datasets() map[string]DatasetStatus {
+	statuses := make(map[string]DatasetStatus, len(add.datasets))
+	for provider, d := range add.datasets {
+		statuses[provider] = d.Status()
+	}
+	return statuses
+}
+
+// ReloadDatasets перечитывает файлы офлайн-провайдеров. Наборы, которые не
+// удалось прочитать, остаются прежними
+func (add *Addition) ReloadDatasets() (map[string]DatasetStatus, error) {
+	var errs []error
+	for _, d := range add.datasets {
+		if err := d.Reload(); err != nil {
+			errs = append(errs, err)
 		}
-		err = json.Unmarshal(bytes, &ageResp)
-		if err != nil {
-			errChan <- err
-			return
+	}
+	return add.Datasets(), errors.Join(errs...)
+}
+
+func (add *Addition) Enrich(ctx context.Context, q Query) (*Result, error) {
+	results, err := add.EnrichBatch(ctx, []Query{q})
+	if err != nil {
+		return nil, err
+	}
+	return results[q], nil
+}
+
+// EnrichBatch обогащает несколько имен пакетными запросами. Запросы
+// дедуплицируются по нормализованному имени и подсказке страны
+func (add *Addition) EnrichBatch(ctx context.Context, qs []Query) (map[Query]*Result, error) {
+	type key struct{ name, hint string }
+	var distinct []Query
+	var names []string
+	index := map[key]int{}
+	for _, q := range qs {
+		name := add.normalize(q.Name)
+		k := key{name, q.CountryHint}
+		if _, ok := index[k]; !ok {
+			index[k] = len(distinct)
+			distinct = append(distinct, q)
+			names = append(names, name)
 		}
-		ageChan <- ageResp.Age
+	}
+	results := make(map[Query]*Result, len(qs))
+	if len(distinct) == 0 {
+		return results, nil
+	}
+
+	// этап 1: национальность
+	nations, nationErr := nationalityBatch(ctx, add.nation, names)
+	if nationErr != nil && !unavailable(ctx, nationErr) {
+		return nil, nationErr
+	}
+	nations = padded(nations, len(distinct))
+
+	countries := make([]string, len(distinct))
+	for i, q := range distinct {
+		countries[i] = add.country(q, nations[i])
+	}
+
+	// этап 2: возраст и пол с привязкой к стране
+	var ages []AgeEstimate
+	var genders []GenderEstimate
+	var ageErr, genderErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		ages, ageErr = localized(ctx, names, countries, add.minLocalCount,
+			func(ctx context.Context, names []string, country string) ([]AgeEstimate, error) {
+				return ageBatch(ctx, add.age, names, country)
+			},
+			func(age AgeEstimate) int { return age.Count })
 	}()
 
 	go func() {
-		respGender, err := add.Get(add.genderizeURL + "/?name=" + name)
-		if err != nil {
-			errChan <- err
-			return
-		}
-		bytes, err := io.ReadAll(respGender.Body)
+		defer wg.Done()
+		genders, genderErr = localized(ctx, names, countries, add.minLocalCount,
+			func(ctx context.Context, names []string, country string) ([]GenderEstimate, error) {
+				return genderBatch(ctx, add.gender, names, country)
+			},
+			func(gender GenderEstimate) int { return gender.Count })
+	}()
+
+	wg.Wait()
+	missing, err := add.missingFields(ctx, ageErr, genderErr, nationErr)
+	if err != nil {
+		return nil, err
+	}
+	// у недоступного провайдера ответа нет, поле остается пустым
+	ages = padded(ages, len(distinct))
+	genders = padded(genders, len(distinct))
+
+	for _, q := range qs {
+		i := index[key{add.normalize(q.Name), q.CountryHint}]
+		res := add.result(ages[i], genders[i], nations[i], missing)
+		res.NormalizedName = names[i]
+		res.Country = countries[i]
+		results[q] = res
+	}
+	return results, nil
+}
+
+func (add *Addition) normalize(name string) string {
+	if add.normalizer == nil {
+		return normalizeKey(name)
+	}
+	return add.normalizer.Normalize(name)
+}
+
+// country страна для второго этапа: подсказка, самая вероятная
+// национальность или страна по умолчанию
+func (add *Addition) country(q Query, nation NationalityEstimate) string {
+	if q.CountryHint != "" {
+		return q.CountryHint
+	}
+	if top := nation.Top().CountryID; top != "" {
+		return top
+	}
+	return add.defaultCountry
+}
+
+// localized запрашивает значения по группам имен с одной страной. Если
+// локальная выборка меньше minCount, для имени берется глобальный ответ,
+// а при его сбое остается локальный
+func localized[T any](ctx context.Context, names, countries []string, minCount int,
+	get func(context.Context, []string, string) ([]T, error), count func(T) int) ([]T, error) {
+	out := make([]T, len(names))
+	groups := map[string][]int{}
+	for i, country := range countries {
+		groups[country] = append(groups[country], i)
+	}
+
+	var fallback []int
+	for country, idx := range groups {
+		vals, err := get(ctx, pick(names, idx), country)
 		if err != nil {
-			errChan <- err
-			return
+			return nil, err
 		}
-		err = json.Unmarshal(bytes, &genderResp)
-		if err != nil {
-			errChan <- err
-			return
+		for j, i := range idx {
+			out[i] = vals[j]
+			if country != "" && count(vals[j]) < minCount {
+				fallback = append(fallback, i)
+			}
 		}
-		genderChan <- genderResp.Gender
-	}()
+	}
+	if len(fallback) == 0 {
+		return out, nil
+	}
 
-	go func() {
-		respNation, err := add.Get(add.nationalizeURL + "/?name=" + name)
-		if err != nil {
-			errChan <- err
-			return
+	vals, err := get(ctx, pick(names, fallback), "")
+	if err != nil {
+		// сбой глобального запроса не стирает полученные локальные ответы,
+		// ошибка - только если ни у одного имени нет данных
+		if slices.ContainsFunc(out, func(v T) bool { return count(v) > 0 }) {
+			return out, nil
 		}
-		bytes, err := io.ReadAll(respNation.Body)
-		if err != nil {
-			errChan <- err
-			return
+		return nil, err
+	}
+	for j, i := range fallback {
+		out[i] = vals[j]
+	}
+	return out, nil
+}
+
+func pick(names []string, idx []int) []string {
+	out := make([]string, len(idx))
+	for j, i := range idx {
+		out[j] = names[i]
+	}
+	return out
+}
+
+func padded[T any](vals []T, n int) []T {
+	if len(vals) == n {
+		return vals
+	}
+	return make([]T, n)
+}
+
+// unavailable провайдер не ответил: разомкнут предохранитель, ошибка сети,
+// 5xx или битое тело. Поле остается пустым, персона сохраняется с
+// enrichment_pending. Исчерпанная квота и отмена запроса фатальны
+func unavailable(ctx context.Context, err error) bool {
+	return !errors.Is(err, cerrors.ErrQuotaExhausted) && ctx.Err() == nil
+}
+
+// missingFields поля недоступных провайдеров, остальные ошибки фатальны
+func (add *Addition) missingFields(ctx context.Context, ageErr, genderErr, nationErr error) ([]string, error) {
+	var missing []string
+	fieldErrs := []struct {
+		field string
+		err   error
+	}{{FieldAge, ageErr}, {FieldGender, genderErr}, {FieldNationality, nationErr}}
+	for _, fe := range fieldErrs {
+		if fe.err == nil {
+			continue
 		}
-		err = json.Unmarshal(bytes, &natResp)
-		if err != nil {
-			errChan <- err
-			return
+		if !unavailable(ctx, fe.err) {
+			return nil, fe.err
 		}
-		nationChan <- natResp.Country[0].CountryID
-	}()
-	var age int
-	var gender, nationality string
-	var err error
-	for i := 0; i < 3; i++ {
-		select {
-		case age = <-ageChan:
-		case gender = <-genderChan:
-		case nationality = <-nationChan:
-		case err = <-errChan:
-			return 0, "", "", err
+		missing = append(missing, fe.field)
+	}
+	return missing, nil
+}
+
+func (add *Addition) result(age AgeEstimate, gender GenderEstimate, nation NationalityEstimate, missing []string) *Result {
+	res := &Result{
+		Age:                    age.Age,
+		AgeCount:               age.Count,
+		Gender:                 gender.Gender,
+		GenderProbability:      gender.Probability,
+		Nationality:            nation.Top().CountryID,
+		NationalityProbability: nation.Top().Probability,
+		Countries:              nation.Country,
+		Missing:                missing,
+		Sources:                map[string]string{},
+	}
+	for _, field := range []string{FieldAge, FieldGender, FieldNationality} {
+		if res.Has(field) {
+			res.Sources[field] = add.names[field]
 		}
 	}
-	return age, gender, nationality, nil
+	return res
 }
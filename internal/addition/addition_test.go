@@ -0,0 +1,269 @@
+package addition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"future_today/internal/config"
+	"future_today/internal/stub"
+	"slices"
+	"testing"
+	"time"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// newStubAddition Addition с тремя HTTP-провайдерами на заглушке
+func newStubAddition(t *testing.T, fx *stub.Fixtures, defaultCountry string) (*Addition, *stub.Server) {
+	t.Helper()
+	ts := stub.NewTestServer(fx)
+	t.Cleanup(ts.Close)
+	cfg := &config.Config{
+		AgeProvider:         "agify",
+		GenderProvider:      "genderize",
+		NationalityProvider: "nationalize",
+		Transliteration:     "icao",
+		DefaultCountry:      defaultCountry,
+		MinLocalCount:       100,
+		AgifyTimeout:        2 * time.Second,
+		GenderizeTimeout:    2 * time.Second,
+		NationalizeTimeout:  2 * time.Second,
+		RetryMax:            2,
+		RetryBaseDelay:      time.Millisecond,
+		RetryMaxDelay:       5 * time.Millisecond,
+		BreakerThreshold:    100,
+		BreakerOpenTimeout:  time.Minute,
+	}
+	cfg.AgifyURL, cfg.GenderizeURL, cfg.NationalizeURL = stub.URLs(ts.URL)
+	add, err := NewAddition(cfg, nil)
+	if err != nil {
+		t.Fatalf("NewAddition: %v", err)
+	}
+	return add, ts.Stub
+}
+
+func testFixtures() *stub.Fixtures {
+	return &stub.Fixtures{RateLimitReset: 5, Names: map[string]*stub.NameFixture{
+		"dmitrii": {
+			Stats:     stub.Stats{Age: ptr(43), AgeCount: 12850, Gender: ptr("male"), GenderProbability: 1, GenderCount: 24512},
+			Countries: []stub.Country{{CountryID: "UA", Probability: 0.12}, {CountryID: "RU", Probability: 0.61}},
+			Localized: map[string]stub.Stats{
+				"RU": {Age: ptr(42), AgeCount: 9310, Gender: ptr("male"), GenderProbability: 1, GenderCount: 18240},
+			},
+		},
+		"olga": {
+			Stats:     stub.Stats{Age: ptr(47), AgeCount: 41200, Gender: ptr("female"), GenderProbability: 1, GenderCount: 80230},
+			Countries: []stub.Country{{CountryID: "RU", Probability: 0.38}},
+		},
+		"nobody":  {Countries: []stub.Country{}},
+		"limited": {Faults: map[string]stub.Fault{stub.AnyAPI: {Status: 429}}},
+		"garbled": {
+			Stats:  stub.Stats{Age: ptr(30), AgeCount: 500, Gender: ptr("male"), GenderProbability: 0.9, GenderCount: 500},
+			Faults: map[string]stub.Fault{stub.APIAgify: {Malformed: true}},
+		},
+		"flaky": {
+			Stats:  stub.Stats{Age: ptr(25), AgeCount: 500, Gender: ptr("female"), GenderProbability: 0.8, GenderCount: 500},
+			Faults: map[string]stub.Fault{stub.APIGenderize: {Status: 503, Times: 2}},
+		},
+		"down": {
+			Stats:  stub.Stats{Age: ptr(60), AgeCount: 500, Gender: ptr("male"), GenderProbability: 0.7, GenderCount: 500},
+			Faults: map[string]stub.Fault{stub.APIGenderize: {Status: 500}},
+		},
+	}}
+}
+
+func TestEnrichBatchFanOut(t *testing.T) {
+	fx := testFixtures()
+	var qs []Query
+	for i := range 12 {
+		name := fmt.Sprintf("name%02d", i)
+		fx.Names[name] = &stub.NameFixture{Stats: stub.Stats{Age: ptr(20 + i), AgeCount: 500}}
+		qs = append(qs, Query{Name: name})
+	}
+	// те же имена в другом регистре и с пробелами запрашиваются один раз
+	qs = append(qs, Query{Name: "NAME00"}, Query{Name: " name01 "}, Query{Name: "Name11"})
+	add, srv := newStubAddition(t, fx, "")
+
+	results, err := add.EnrichBatch(context.Background(), qs)
+	if err != nil {
+		t.Fatalf("EnrichBatch: %v", err)
+	}
+	if len(results) != len(qs) {
+		t.Fatalf("%d results for %d queries", len(results), len(qs))
+	}
+	for _, q := range qs {
+		res := results[q]
+		if res == nil {
+			t.Fatalf("no result for %q", q.Name)
+		}
+		var i int
+		fmt.Sscanf(res.NormalizedName, "name%02d", &i)
+		if res.Age != 20+i {
+			t.Errorf("%q: age %d, want %d", q.Name, res.Age, 20+i)
+		}
+	}
+	// 12 разных имен при MaxBatchSize 10 - два пакетных запроса в каждый API
+	for _, api := range []string{stub.APIAgify, stub.APIGenderize, stub.APINationalize} {
+		if n := srv.Requests(api); n != 2 {
+			t.Errorf("%s: %d requests, want 2", api, n)
+		}
+	}
+}
+
+func TestEnrichLocalized(t *testing.T) {
+	add, srv := newStubAddition(t, testFixtures(), "")
+	results, err := add.EnrichBatch(context.Background(), []Query{{Name: "Дмитрий"}, {Name: "Olga"}})
+	if err != nil {
+		t.Fatalf("EnrichBatch: %v", err)
+	}
+
+	dmitrii := results[Query{Name: "Дмитрий"}]
+	if dmitrii.NormalizedName != "dmitrii" || dmitrii.Country != "RU" || dmitrii.Age != 42 || dmitrii.AgeCount != 9310 {
+		t.Errorf("dmitrii = %+v, want localized RU answer", dmitrii)
+	}
+	// страны отсортированы по убыванию вероятности
+	if dmitrii.Nationality != "RU" || dmitrii.Countries[1].CountryID != "UA" {
+		t.Errorf("dmitrii countries = %+v", dmitrii.Countries)
+	}
+	// у olga нет выборки по RU, берется глобальный ответ
+	if olga := results[Query{Name: "Olga"}]; olga.Age != 47 || olga.Gender != "female" {
+		t.Errorf("olga = %+v, want global answer", olga)
+	}
+	// запрос по RU на оба имени и глобальный для olga
+	if n := srv.Requests(stub.APIAgify); n != 2 {
+		t.Errorf("agify: %d requests, want 2", n)
+	}
+}
+
+func TestEnrichQuotaError(t *testing.T) {
+	add, srv := newStubAddition(t, testFixtures(), "")
+	_, err := add.Enrich(context.Background(), Query{Name: "limited"})
+	var qe *QuotaError
+	if !errors.As(err, &qe) {
+		t.Fatalf("Enrich error = %v, want *QuotaError", err)
+	}
+	if qe.Provider != "nationalize" || qe.Reset <= 0 || qe.Reset > 5*time.Second {
+		t.Errorf("quota error = %+v, want nationalize with reset up to 5s", qe)
+	}
+	// квота не повторяется
+	if n := srv.Requests(stub.APINationalize); n != 1 {
+		t.Errorf("nationalize: %d requests, want 1", n)
+	}
+}
+
+func TestEnrichMalformedJSON(t *testing.T) {
+	add, srv := newStubAddition(t, testFixtures(), "")
+	res, err := add.Enrich(context.Background(), Query{Name: "garbled"})
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if !slices.Equal(res.Missing, []string{FieldAge}) || res.Has(FieldAge) {
+		t.Errorf("missing = %v, want [age]", res.Missing)
+	}
+	if res.Age != 0 || res.Gender != "male" {
+		t.Errorf("age = %d, gender = %q", res.Age, res.Gender)
+	}
+	if _, ok := res.Sources[FieldAge]; ok {
+		t.Errorf("sources = %v, age has no source", res.Sources)
+	}
+	if n := srv.Requests(stub.APIAgify); n != 3 {
+		t.Errorf("agify: %d requests, want 3 with retries", n)
+	}
+}
+
+func TestEnrichRetries5xx(t *testing.T) {
+	add, srv := newStubAddition(t, testFixtures(), "")
+	res, err := add.Enrich(context.Background(), Query{Name: "flaky"})
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if res.Pending() || res.Gender != "female" {
+		t.Errorf("flaky = %+v, want gender after retries", res)
+	}
+	if n := srv.Requests(stub.APIGenderize); n != 3 {
+		t.Errorf("genderize: %d requests, want 2 failures and a success", n)
+	}
+
+	res, err = add.Enrich(context.Background(), Query{Name: "down"})
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if !slices.Equal(res.Missing, []string{FieldGender}) || res.Age != 60 {
+		t.Errorf("down = %+v, want only gender missing", res)
+	}
+	if n := srv.Requests(stub.APIGenderize) - 3; n != 3 {
+		t.Errorf("genderize: %d requests for down, want 3", n)
+	}
+}
+
+func TestEnrichEmptyCountries(t *testing.T) {
+	add, _ := newStubAddition(t, testFixtures(), "KZ")
+	res, err := add.Enrich(context.Background(), Query{Name: "nobody"})
+	if err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if res.Pending() {
+		t.Errorf("missing = %v, empty answer isn't a failure", res.Missing)
+	}
+	if res.Nationality != "" || res.NationalityProbability != 0 || len(res.Countries) != 0 {
+		t.Errorf("nationality = %q %v %v, want empty", res.Nationality, res.NationalityProbability, res.Countries)
+	}
+	// без национальности возраст и пол привязываются к стране по умолчанию
+	if res.Country != "KZ" {
+		t.Errorf("country = %q, want default KZ", res.Country)
+	}
+}
+
+func TestLocalizedFallbackError(t *testing.T) {
+	local := map[string]AgeEstimate{
+		"dmitrii": {Age: 42, Count: 9310},
+		"olga":    {Age: 50, Count: 20},
+		"nobody":  {},
+	}
+	errFallback := errors.New("global request failed")
+	get := func(_ context.Context, names []string, country string) ([]AgeEstimate, error) {
+		if country == "" {
+			return nil, errFallback
+		}
+		out := make([]AgeEstimate, len(names))
+		for i, name := range names {
+			out[i] = local[name]
+		}
+		return out, nil
+	}
+	count := func(age AgeEstimate) int { return age.Count }
+
+	tests := []struct {
+		name    string
+		names   []string
+		want    []AgeEstimate
+		wantErr bool
+	}{
+		{"keeps local answers", []string{"dmitrii", "olga"}, []AgeEstimate{local["dmitrii"], local["olga"]}, false},
+		{"small sample is still data", []string{"olga", "nobody"}, []AgeEstimate{local["olga"], {}}, false},
+		{"nothing received", []string{"nobody"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			countries := make([]string, len(tt.names))
+			for i := range countries {
+				countries[i] = "RU"
+			}
+			got, err := localized(context.Background(), tt.names, countries, 100, get, count)
+			if tt.wantErr {
+				if !errors.Is(err, errFallback) {
+					t.Errorf("error = %v, want fallback error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("localized: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
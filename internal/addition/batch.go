@@ -0,0 +1,190 @@
+package addition
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// MaxBatchSize сколько имен agify, genderize и nationalize принимают за запрос
+const MaxBatchSize = 10
+
+// Пакетные возможности провайдера. Ответ выровнен по входным именам
+type BatchAgeProvider interface {
+	GetAgeBatch(ctx context.Context, names []string, countryID string) ([]AgeEstimate, error)
+}
+
+type BatchGenderProvider interface {
+	GetGenderBatch(ctx context.Context, names []string, countryID string) ([]GenderEstimate, error)
+}
+
+type BatchNationalityProvider interface {
+	GetNationalityBatch(ctx context.Context, names []string) ([]NationalityEstimate, error)
+}
+
+func ageBatch(ctx context.Context, p AgeProvider, names []string, countryID string) ([]AgeEstimate, error) {
+	if b, ok := p.(BatchAgeProvider); ok {
+		return b.GetAgeBatch(ctx, names, countryID)
+	}
+	return eachName(ctx, names, func(ctx context.Context, name string) (AgeEstimate, error) {
+		return p.GetAge(ctx, name, countryID)
+	})
+}
+
+func genderBatch(ctx context.Context, p GenderProvider, names []string, countryID string) ([]GenderEstimate, error) {
+	if b, ok := p.(BatchGenderProvider); ok {
+		return b.GetGenderBatch(ctx, names, countryID)
+	}
+	return eachName(ctx, names, func(ctx context.Context, name string) (GenderEstimate, error) {
+		return p.GetGender(ctx, name, countryID)
+	})
+}
+
+func nationalityBatch(ctx context.Context, p NationalityProvider, names []string) ([]NationalityEstimate, error) {
+	if b, ok := p.(BatchNationalityProvider); ok {
+		return b.GetNationalityBatch(ctx, names)
+	}
+	return eachName(ctx, names, p.GetNationality)
+}
+
+// eachName запасной вариант для провайдеров без пакетного режима
+func eachName[T any](ctx context.Context, names []string, get func(context.Context, string) (T, error)) ([]T, error) {
+	out := make([]T, len(names))
+	for i, name := range names {
+		v, err := get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// inChunks запрашивает имена пачками по MaxBatchSize
+func inChunks[T any](ctx context.Context, names []string, get func(context.Context, []string) ([]T, error)) ([]T, error) {
+	out := make([]T, 0, len(names))
+	for start := 0; start < len(names); start += MaxBatchSize {
+		chunk := names[start:min(start+MaxBatchSize, len(names))]
+		vals, err := get(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != len(chunk) {
+			return nil, fmt.Errorf("batch answer has %d items for %d names", len(vals), len(chunk))
+		}
+		out = append(out, vals...)
+	}
+	return out, nil
+}
+
+func batchURL(base string, names []string) string {
+	q := url.Values{"name[]": names}
+	return base + "/?" + q.Encode()
+}
+
+func (a *Agify) GetAgeBatch(ctx context.Context, names []string, countryID string) ([]AgeEstimate, error) {
+	return inChunks(ctx, names, func(ctx context.Context, chunk []string) ([]AgeEstimate, error) {
+		var ages []AgeEstimate
+		if err := a.http.getJSON(ctx, localizedURL(batchURL(a.url, chunk), countryID), len(chunk), &ages); err != nil {
+			return nil, fmt.Errorf("error getting age batch by url: %w", err)
+		}
+		return ages, nil
+	})
+}
+
+func (g *Genderize) GetGenderBatch(ctx context.Context, names []string, countryID string) ([]GenderEstimate, error) {
+	return inChunks(ctx, names, func(ctx context.Context, chunk []string) ([]GenderEstimate, error) {
+		var genders []GenderEstimate
+		if err := g.http.getJSON(ctx, localizedURL(batchURL(g.url, chunk), countryID), len(chunk), &genders); err != nil {
+			return nil, fmt.Errorf("error getting gender batch by url: %w", err)
+		}
+		return genders, nil
+	})
+}
+
+func (n *Nationalize) GetNationalityBatch(ctx context.Context, names []string) ([]NationalityEstimate, error) {
+	return inChunks(ctx, names, func(ctx context.Context, chunk []string) ([]NationalityEstimate, error) {
+		var nations []NationalityEstimate
+		if err := n.http.getJSON(ctx, batchURL(n.url, chunk), len(chunk), &nations); err != nil {
+			return nil, fmt.Errorf("error getting nation batch by url: %w", err)
+		}
+		for _, nation := range nations {
+			nation.sortCountries()
+		}
+		return nations, nil
+	})
+}
+
+// кэш: из провайдера запрашиваются только промахи
+
+func cachedBatch[T any](ctx context.Context, cache *Cache, provider, countryID string, names []string,
+	next func(context.Context, []string) ([]T, error)) ([]T, error) {
+	out := make([]T, len(names))
+	var missIdx []int
+	var missNames []string
+	for i, name := range names {
+		if !cache.Get(provider, name, countryID, &out[i]) {
+			missIdx = append(missIdx, i)
+			missNames = append(missNames, name)
+		}
+	}
+	if len(missNames) == 0 {
+		return out, nil
+	}
+	vals, err := next(ctx, missNames)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range missIdx {
+		out[i] = vals[j]
+		cache.Set(provider, names[i], countryID, vals[j])
+	}
+	return out, nil
+}
+
+func (p *cachedAge) GetAgeBatch(ctx context.Context, names []string, countryID string) ([]AgeEstimate, error) {
+	return cachedBatch(ctx, p.cache, p.provider, countryID, names, func(ctx context.Context, names []string) ([]AgeEstimate, error) {
+		return ageBatch(ctx, p.next, names, countryID)
+	})
+}
+
+func (p *cachedGender) GetGenderBatch(ctx context.Context, names []string, countryID string) ([]GenderEstimate, error) {
+	return cachedBatch(ctx, p.cache, p.provider, countryID, names, func(ctx context.Context, names []string) ([]GenderEstimate, error) {
+		return genderBatch(ctx, p.next, names, countryID)
+	})
+}
+
+func (p *cachedNationality) GetNationalityBatch(ctx context.Context, names []string) ([]NationalityEstimate, error) {
+	return cachedBatch(ctx, p.cache, p.provider, "", names, func(ctx context.Context, names []string) ([]NationalityEstimate, error) {
+		return nationalityBatch(ctx, p.next, names)
+	})
+}
+
+// предохранитель: пакет считается одним вызовом провайдера
+
+func (p *breakerAge) GetAgeBatch(ctx context.Context, names []string, countryID string) ([]AgeEstimate, error) {
+	var ages []AgeEstimate
+	err := p.breaker.Do(ctx, func() (err error) {
+		ages, err = ageBatch(ctx, p.next, names, countryID)
+		return err
+	})
+	return ages, err
+}
+
+func (p *breakerGender) GetGenderBatch(ctx context.Context, names []string, countryID string) ([]GenderEstimate, error) {
+	var genders []GenderEstimate
+	err := p.breaker.Do(ctx, func() (err error) {
+		genders, err = genderBatch(ctx, p.next, names, countryID)
+		return err
+	})
+	return genders, err
+}
+
+func (p *breakerNationality) GetNationalityBatch(ctx context.Context, names []string) ([]NationalityEstimate, error) {
+	var nations []NationalityEstimate
+	err := p.breaker.Do(ctx, func() (err error) {
+		nations, err = nationalityBatch(ctx, p.next, names)
+		return err
+	})
+	return nations, err
+}
@@ -0,0 +1,159 @@
+package addition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"future_today/internal/cerrors"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// Breaker размыкается после threshold ошибок подряд и через openTimeout
+// пропускает один пробный запрос
+type Breaker struct {
+	mu          sync.Mutex
+	provider    string
+	threshold   int
+	openTimeout time.Duration
+	state       BreakerState
+	failures    int
+	openedAt    time.Time
+	probing     bool
+	lastErr     error
+}
+
+type BreakerStatus struct {
+	Provider  string       `json:"provider"`
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"failures"`
+	OpenedAt  *time.Time   `json:"opened_at,omitempty"`
+	LastError string       `json:"last_error,omitempty"`
+}
+
+func NewBreaker(provider string, threshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{provider: provider, threshold: threshold, openTimeout: openTimeout, state: BreakerClosed}
+}
+
+// Do выполняет fn, если цепь не разомкнута
+func (b *Breaker) Do(ctx context.Context, fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	// отмена запроса клиентом и исчерпанная квота не говорят о здоровье провайдера
+	if err != nil && (errors.Is(err, cerrors.ErrQuotaExhausted) || ctx.Err() != nil && errors.Is(err, ctx.Err())) {
+		b.release()
+		return err
+	}
+	b.record(err)
+	return err
+}
+
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return fmt.Errorf("%w: %s", cerrors.ErrBreakerOpen, b.provider)
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+	case BreakerHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: %s", cerrors.ErrBreakerOpen, b.provider)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	b.lastErr = err
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := BreakerStatus{Provider: b.provider, State: b.state, Failures: b.failures}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if b.lastErr != nil {
+		status.LastError = b.lastErr.Error()
+	}
+	return status
+}
+
+// обертки провайдеров с предохранителем
+
+type breakerAge struct {
+	next    AgeProvider
+	breaker *Breaker
+}
+
+func (p *breakerAge) GetAge(ctx context.Context, name, countryID string) (AgeEstimate, error) {
+	var age AgeEstimate
+	err := p.breaker.Do(ctx, func() (err error) {
+		age, err = p.next.GetAge(ctx, name, countryID)
+		return err
+	})
+	return age, err
+}
+
+type breakerGender struct {
+	next    GenderProvider
+	breaker *Breaker
+}
+
+func (p *breakerGender) GetGender(ctx context.Context, name, countryID string) (GenderEstimate, error) {
+	var gender GenderEstimate
+	err := p.breaker.Do(ctx, func() (err error) {
+		gender, err = p.next.GetGender(ctx, name, countryID)
+		return err
+	})
+	return gender, err
+}
+
+type breakerNationality struct {
+	next    NationalityProvider
+	breaker *Breaker
+}
+
+func (p *breakerNationality) GetNationality(ctx context.Context, name string) (NationalityEstimate, error) {
+	var nation NationalityEstimate
+	err := p.breaker.Do(ctx, func() (err error) {
+		nation, err = p.next.GetNationality(ctx, name)
+		return err
+	})
+	return nation, err
+}
@@ -0,0 +1,212 @@
+package addition
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStore постоянный уровень кэша (таблица name_enrichment)
+type CacheStore interface {
+	GetEnrichment(provider, name, country string) (payload []byte, updatedAt time.Time, found bool, err error)
+	SetEnrichment(provider, name, country string, payload []byte) error
+	InvalidateEnrichment(provider string) error
+}
+
+// Cache двухуровневый кэш ответов провайдеров: LRU в памяти и CacheStore.
+// Ключ - имя провайдера, нормализованное имя и страна запроса (пустая
+// для глобального ответа)
+type Cache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[cacheKey]*list.Element
+	store CacheStore
+	// now подменяется в тестах
+	now func() time.Time
+}
+
+type cacheKey struct {
+	provider string
+	name     string
+	country  string
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	payload   []byte
+	expiresAt time.Time
+}
+
+// NewCache, store может быть nil - тогда работает только LRU
+func NewCache(size int, ttl time.Duration, store CacheStore) *Cache {
+	return &Cache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[cacheKey]*list.Element),
+		store: store,
+		now:   time.Now,
+	}
+}
+
+func normalizeKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Get достает значение провайдера для имени в out, false если промах
+func (c *Cache) Get(provider, name, country string, out any) bool {
+	key := cacheKey{provider: provider, name: normalizeKey(name), country: country}
+
+	if payload, ok := c.getLRU(key); ok {
+		return json.Unmarshal(payload, out) == nil
+	}
+	if c.store == nil {
+		return false
+	}
+
+	payload, updatedAt, found, err := c.store.GetEnrichment(key.provider, key.name, key.country)
+	if err != nil || !found {
+		return false
+	}
+	expiresAt := updatedAt.Add(c.ttl)
+	if c.now().After(expiresAt) {
+		return false
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return false
+	}
+	c.setLRU(key, payload, expiresAt)
+	return true
+}
+
+// Set кладет значение в оба уровня, ошибки постоянного уровня не критичны
+func (c *Cache) Set(provider, name, country string, v any) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	key := cacheKey{provider: provider, name: normalizeKey(name), country: country}
+	c.setLRU(key, payload, c.now().Add(c.ttl))
+	if c.store != nil {
+		_ = c.store.SetEnrichment(key.provider, key.name, key.country, payload)
+	}
+}
+
+// Invalidate сбрасывает все закэшированные ответы провайдера
+func (c *Cache) Invalidate(provider string) error {
+	c.mu.Lock()
+	for key, el := range c.items {
+		if key.provider == provider {
+			c.ll.Remove(el)
+			delete(c.items, key)
+		}
+	}
+	c.mu.Unlock()
+
+	if c.store == nil {
+		return nil
+	}
+	return c.store.InvalidateEnrichment(provider)
+}
+
+func (c *Cache) getLRU(key cacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if c.now().After(entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return entry.payload, true
+}
+
+func (c *Cache) setLRU(key cacheKey, payload []byte, expiresAt time.Time) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.payload = payload
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, payload: payload, expiresAt: expiresAt})
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// обертки провайдеров с кэшем
+
+type cachedAge struct {
+	next     AgeProvider
+	provider string
+	cache    *Cache
+}
+
+func (p *cachedAge) GetAge(ctx context.Context, name, countryID string) (AgeEstimate, error) {
+	var age AgeEstimate
+	if p.cache.Get(p.provider, name, countryID, &age) {
+		return age, nil
+	}
+	age, err := p.next.GetAge(ctx, name, countryID)
+	if err != nil {
+		return AgeEstimate{}, err
+	}
+	p.cache.Set(p.provider, name, countryID, age)
+	return age, nil
+}
+
+type cachedGender struct {
+	next     GenderProvider
+	provider string
+	cache    *Cache
+}
+
+func (p *cachedGender) GetGender(ctx context.Context, name, countryID string) (GenderEstimate, error) {
+	var gender GenderEstimate
+	if p.cache.Get(p.provider, name, countryID, &gender) {
+		return gender, nil
+	}
+	gender, err := p.next.GetGender(ctx, name, countryID)
+	if err != nil {
+		return GenderEstimate{}, err
+	}
+	p.cache.Set(p.provider, name, countryID, gender)
+	return gender, nil
+}
+
+type cachedNationality struct {
+	next     NationalityProvider
+	provider string
+	cache    *Cache
+}
+
+func (p *cachedNationality) GetNationality(ctx context.Context, name string) (NationalityEstimate, error) {
+	var nation NationalityEstimate
+	if p.cache.Get(p.provider, name, "", &nation) {
+		return nation, nil
+	}
+	nation, err := p.next.GetNationality(ctx, name)
+	if err != nil {
+		return NationalityEstimate{}, err
+	}
+	p.cache.Set(p.provider, name, "", nation)
+	return nation, nil
+}
@@ -0,0 +1,173 @@
+package addition
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// memoryStore CacheStore в памяти с заданным временем записи
+type memoryStore struct {
+	entries map[cacheKey]storedEntry
+	now     time.Time
+}
+
+type storedEntry struct {
+	payload   []byte
+	updatedAt time.Time
+}
+
+func newMemoryStore(now time.Time) *memoryStore {
+	return &memoryStore{entries: map[cacheKey]storedEntry{}, now: now}
+}
+
+func (s *memoryStore) GetEnrichment(provider, name, country string) ([]byte, time.Time, bool, error) {
+	e, ok := s.entries[cacheKey{provider, name, country}]
+	return e.payload, e.updatedAt, ok, nil
+}
+
+func (s *memoryStore) SetEnrichment(provider, name, country string, payload []byte) error {
+	s.entries[cacheKey{provider, name, country}] = storedEntry{payload, s.now}
+	return nil
+}
+
+func (s *memoryStore) InvalidateEnrichment(provider string) error {
+	for key := range s.entries {
+		if key.provider == provider {
+			delete(s.entries, key)
+		}
+	}
+	return nil
+}
+
+// testClock часы кэша, которые двигает тест
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time { return c.t }
+
+func newTestCache(size int, store CacheStore) (*Cache, *testClock) {
+	clock := &testClock{t: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewCache(size, time.Hour, store)
+	c.now = clock.now
+	return c, clock
+}
+
+func TestCacheLRUEviction(t *testing.T) {
+	tests := []struct {
+		name string
+		// set - положить, get - прочитать, обновляя порядок
+		ops  []string
+		hits []string
+		miss []string
+	}{
+		{"oldest evicted", []string{"set a", "set b", "set c"}, []string{"b", "c"}, []string{"a"}},
+		{"read keeps entry", []string{"set a", "set b", "get a", "set c"}, []string{"a", "c"}, []string{"b"}},
+		{"overwrite keeps entry", []string{"set a", "set b", "set a", "set c"}, []string{"a", "c"}, []string{"b"}},
+		{"names normalized", []string{"set Anna", "set b", "get  ANNA ", "set c"}, []string{"anna", "c"}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCache(2, nil)
+			for _, op := range tt.ops {
+				var v AgeEstimate
+				switch op[:4] {
+				case "set ":
+					c.Set("agify", op[4:], "", AgeEstimate{Age: 30})
+				case "get ":
+					c.Get("agify", op[4:], "", &v)
+				}
+			}
+			for _, name := range tt.hits {
+				var v AgeEstimate
+				if !c.Get("agify", name, "", &v) || v.Age != 30 {
+					t.Errorf("%s: miss, want hit", name)
+				}
+			}
+			for _, name := range tt.miss {
+				var v AgeEstimate
+				if c.Get("agify", name, "", &v) {
+					t.Errorf("%s: hit, want evicted", name)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		elapsed time.Duration
+		hit     bool
+	}{
+		{"lru fresh", 10, 59 * time.Minute, true},
+		{"lru expired", 10, 61 * time.Minute, false},
+		{"store fresh", 0, 59 * time.Minute, true},
+		{"store expired", 0, 61 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+			c, clock := newTestCache(tt.size, store)
+			c.Set("agify", "anna", "RU", AgeEstimate{Age: 30, Count: 100})
+			clock.t = clock.t.Add(tt.elapsed)
+			var v AgeEstimate
+			if got := c.Get("agify", "anna", "RU", &v); got != tt.hit {
+				t.Errorf("Get = %v, want %v", got, tt.hit)
+			}
+		})
+	}
+}
+
+func TestCacheStoreLevel(t *testing.T) {
+	store := newMemoryStore(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	c, _ := newTestCache(10, store)
+	c.Set("agify", "Anna", "RU", AgeEstimate{Age: 30})
+	c.Set("genderize", "Anna", "RU", GenderEstimate{Gender: "female"})
+	if _, ok := store.entries[cacheKey{"agify", "anna", "RU"}]; !ok {
+		t.Fatalf("store entries = %v, want normalized key", store.entries)
+	}
+
+	// новый процесс: LRU пуст, ответ берется из постоянного уровня
+	restarted, _ := newTestCache(10, store)
+	var age AgeEstimate
+	if !restarted.Get("agify", "ANNA", "RU", &age) || age.Age != 30 {
+		t.Errorf("age from store = %v", age)
+	}
+	if restarted.Get("agify", "anna", "", &age) {
+		t.Error("global answer served from the RU entry")
+	}
+
+	if err := restarted.Invalidate("agify"); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	if restarted.Get("agify", "anna", "RU", &age) {
+		t.Error("agify entry survived invalidation")
+	}
+	var gender GenderEstimate
+	if !restarted.Get("genderize", "anna", "RU", &gender) || gender.Gender != "female" {
+		t.Error("genderize entry dropped by agify invalidation")
+	}
+}
+
+// countingAge провайдер возраста, считающий вызовы
+type countingAge struct{ calls int }
+
+func (p *countingAge) GetAge(_ context.Context, name, _ string) (AgeEstimate, error) {
+	p.calls++
+	return AgeEstimate{Age: len(name)}, nil
+}
+
+func TestCachedProvider(t *testing.T) {
+	next := &countingAge{}
+	c, _ := newTestCache(10, nil)
+	p := &cachedAge{next: next, provider: "agify", cache: c}
+	for _, name := range []string{"anna", "Anna", "anna", "boris"} {
+		if _, err := p.GetAge(context.Background(), name, ""); err != nil {
+			t.Fatalf("GetAge: %v", err)
+		}
+	}
+	if next.calls != 2 {
+		t.Errorf("%d provider calls, want 2", next.calls)
+	}
+}
@@ -0,0 +1,259 @@
+package addition
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"future_today/internal/normalize"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Колонки файла статистики имен. Строка с пустым country_id - глобальная
+// статистика имени, с заполненным - статистика по стране и доля имени
+// в этой стране (nationality_probability)
+const (
+	colName                   = "name"
+	colCountry                = "country_id"
+	colAge                    = "age"
+	colAgeCount               = "age_count"
+	colGender                 = "gender"
+	colGenderProbability      = "gender_probability"
+	colGenderCount            = "gender_count"
+	colNationalityProbability = "nationality_probability"
+)
+
+type datasetRow struct {
+	age    AgeEstimate
+	gender GenderEstimate
+}
+
+type datasetName struct {
+	// статистика по странам, "" - глобальная
+	rows   map[string]datasetRow
+	nation NationalityEstimate
+}
+
+// DatasetStatus состояние загруженного набора данных
+type DatasetStatus struct {
+	Path     string    `json:"path"`
+	Names    int       `json:"names"`
+	Rows     int       `json:"rows"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// Dataset офлайн-провайдер: отвечает на запросы возраста, пола и
+// национальности по локальному CSV со статистикой имен. Индекс целиком
+// в памяти, Reload подменяет его без остановки запросов
+type Dataset struct {
+	path string
+	// имена в файле приводятся к тому же виду, что и в запросах
+	normalizer *normalize.Normalizer
+
+	mu     sync.RWMutex
+	index  map[string]*datasetName
+	status DatasetStatus
+}
+
+var (
+	datasetsMu sync.Mutex
+	datasets   = map[string]*Dataset{}
+)
+
+// openDataset один экземпляр на файл, даже если провайдер отвечает за
+// несколько полей
+func openDataset(path string, normalizer *normalize.Normalizer) (*Dataset, error) {
+	datasetsMu.Lock()
+	defer datasetsMu.Unlock()
+	if d, ok := datasets[path]; ok {
+		return d, nil
+	}
+	d, err := NewDataset(path, normalizer)
+	if err != nil {
+		return nil, err
+	}
+	datasets[path] = d
+	return d, nil
+}
+
+func NewDataset(path string, normalizer *normalize.Normalizer) (*Dataset, error) {
+	d := &Dataset{path: path, normalizer: normalizer}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload перечитывает файл. При ошибке остается прежний индекс
+func (d *Dataset) Reload() error {
+	f, err := os.Open(d.path)
+	if err != nil {
+		return fmt.Errorf("error opening dataset: %w", err)
+	}
+	defer f.Close()
+
+	index, rows, err := d.read(f)
+	if err != nil {
+		return fmt.Errorf("error reading dataset %s: %w", d.path, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.index = index
+	d.status = DatasetStatus{Path: d.path, Names: len(index), Rows: rows, LoadedAt: time.Now()}
+	return nil
+}
+
+func (d *Dataset) Status() DatasetStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.status
+}
+
+func (d *Dataset) read(r io.Reader) (map[string]*datasetName, int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, col := range header {
+		cols[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	if _, ok := cols[colName]; !ok {
+		return nil, 0, fmt.Errorf("column %q is required", colName)
+	}
+
+	index := map[string]*datasetName{}
+	rows := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		line, _ := reader.FieldPos(0)
+		row := datasetRecord{record: record, cols: cols}
+		name := d.key(row.str(colName))
+		if name == "" {
+			continue
+		}
+		country := strings.ToUpper(row.str(colCountry))
+		stats := datasetRow{
+			age: AgeEstimate{Age: row.integer(colAge), Count: row.integer(colAgeCount)},
+			gender: GenderEstimate{
+				Gender:      row.str(colGender),
+				Probability: row.float(colGenderProbability),
+				Count:       row.integer(colGenderCount),
+			},
+		}
+		nationProbability := row.float(colNationalityProbability)
+		if row.err != nil {
+			return nil, 0, fmt.Errorf("line %d: %w", line, row.err)
+		}
+
+		entry, ok := index[name]
+		if !ok {
+			entry = &datasetName{rows: map[string]datasetRow{}}
+			index[name] = entry
+		}
+		entry.rows[country] = stats
+		if country != "" && nationProbability > 0 {
+			entry.nation.Country = append(entry.nation.Country,
+				CountryProbability{CountryID: country, Probability: nationProbability})
+		}
+		rows++
+	}
+	for _, entry := range index {
+		entry.nation.sortCountries()
+	}
+	return index, rows, nil
+}
+
+// datasetRecord читает колонки строки по имени, пустые и отсутствующие
+// колонки дают нулевое значение
+type datasetRecord struct {
+	record []string
+	cols   map[string]int
+	err    error
+}
+
+func (r *datasetRecord) str(col string) string {
+	i, ok := r.cols[col]
+	if !ok || i >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[i])
+}
+
+func (r *datasetRecord) integer(col string) int {
+	v := r.str(col)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("column %s: %w", col, err)
+	}
+	return n
+}
+
+func (r *datasetRecord) float(col string) float64 {
+	v := r.str(col)
+	if v == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("column %s: %w", col, err)
+	}
+	return f
+}
+
+func (d *Dataset) key(name string) string {
+	if d.normalizer == nil {
+		return normalizeKey(name)
+	}
+	return d.normalizer.Normalize(name)
+}
+
+func (d *Dataset) lookup(name, countryID string) (datasetRow, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	entry, ok := d.index[d.key(name)]
+	if !ok {
+		return datasetRow{}, false
+	}
+	row, ok := entry.rows[strings.ToUpper(countryID)]
+	return row, ok
+}
+
+// GetAge неизвестное имя или страна дают пустой ответ, как у agify
+func (d *Dataset) GetAge(ctx context.Context, name, countryID string) (AgeEstimate, error) {
+	row, _ := d.lookup(name, countryID)
+	return row.age, nil
+}
+
+func (d *Dataset) GetGender(ctx context.Context, name, countryID string) (GenderEstimate, error) {
+	row, _ := d.lookup(name, countryID)
+	return row.gender, nil
+}
+
+func (d *Dataset) GetNationality(ctx context.Context, name string) (NationalityEstimate, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	entry, ok := d.index[d.key(name)]
+	if !ok {
+		return NationalityEstimate{}, nil
+	}
+	// копия, чтобы вызывающий не менял индекс
+	return NationalityEstimate{Country: append([]CountryProbability(nil), entry.nation.Country...)}, nil
+}
@@ -0,0 +1,160 @@
+package addition
+
+import (
+	"context"
+	"future_today/internal/normalize"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const datasetV1 = `name,country_id,age,age_count,gender,gender_probability,gender_count,nationality_probability
+Дмитрий,,43,12850,male,1,24512,
+Дмитрий,ru,42,9310,male,1,18240,0.61
+Дмитрий,UA,45,800,male,0.99,900,0.12
+Olga,,47,41200,female,1,80230,
+`
+
+const datasetV2 = `name,country_id,age,age_count
+dmitrii,,50,100
+Vera,,33,500
+`
+
+func writeDataset(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write dataset: %v", err)
+	}
+}
+
+func newTestDataset(t *testing.T, data string) (*Dataset, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "names.csv")
+	writeDataset(t, path, data)
+	n, err := normalize.New("icao")
+	if err != nil {
+		t.Fatalf("normalize.New: %v", err)
+	}
+	d, err := NewDataset(path, n)
+	if err != nil {
+		t.Fatalf("NewDataset: %v", err)
+	}
+	return d, path
+}
+
+func TestDatasetLookup(t *testing.T) {
+	d, _ := newTestDataset(t, datasetV1)
+	ctx := context.Background()
+	tests := []struct {
+		name, country string
+		age           AgeEstimate
+	}{
+		{"Дмитрий", "", AgeEstimate{Age: 43, Count: 12850}},
+		{"dmitrii", "RU", AgeEstimate{Age: 42, Count: 9310}},
+		{" ДМИТРИЙ ", "ua", AgeEstimate{Age: 45, Count: 800}},
+		{"Дмитрий", "KZ", AgeEstimate{}},
+		{"Nobody", "", AgeEstimate{}},
+	}
+	for _, tt := range tests {
+		if age, _ := d.GetAge(ctx, tt.name, tt.country); age != tt.age {
+			t.Errorf("GetAge(%q, %q) = %+v, want %+v", tt.name, tt.country, age, tt.age)
+		}
+	}
+	gender, _ := d.GetGender(ctx, "Olga", "")
+	if gender != (GenderEstimate{Gender: "female", Probability: 1, Count: 80230}) {
+		t.Errorf("olga gender = %+v", gender)
+	}
+	nation, _ := d.GetNationality(ctx, "Дмитрий")
+	want := []CountryProbability{{CountryID: "RU", Probability: 0.61}, {CountryID: "UA", Probability: 0.12}}
+	if !reflect.DeepEqual(nation.Country, want) {
+		t.Errorf("nationality = %+v, want %+v", nation.Country, want)
+	}
+	if s := d.Status(); s.Names != 2 || s.Rows != 4 {
+		t.Errorf("status = %+v, want 2 names, 4 rows", s)
+	}
+}
+
+func TestDatasetReload(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace func(t *testing.T, path string)
+		wantErr bool
+		// возраст dmitrii после Reload
+		age int
+	}{
+		{"new file", func(t *testing.T, path string) { writeDataset(t, path, datasetV2) }, false, 50},
+		{"bad number", func(t *testing.T, path string) { writeDataset(t, path, "name,age\nvera,old\n") }, true, 43},
+		{"no name column", func(t *testing.T, path string) { writeDataset(t, path, "age\n30\n") }, true, 43},
+		{"empty file", func(t *testing.T, path string) { writeDataset(t, path, "") }, true, 43},
+		{"file removed", func(t *testing.T, path string) { os.Remove(path) }, true, 43},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, path := newTestDataset(t, datasetV1)
+			before := d.Status()
+			tt.replace(t, path)
+			err := d.Reload()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Reload error = %v, want error %v", err, tt.wantErr)
+			}
+			if age, _ := d.GetAge(context.Background(), "dmitrii", ""); age.Age != tt.age {
+				t.Errorf("age = %d, want %d", age.Age, tt.age)
+			}
+			if tt.wantErr {
+				// при ошибке остается прежний индекс
+				if d.Status() != before {
+					t.Errorf("status = %+v, want unchanged %+v", d.Status(), before)
+				}
+				return
+			}
+			if s := d.Status(); s.Names != 2 || s.Rows != 2 || s.LoadedAt.Before(before.LoadedAt) {
+				t.Errorf("status = %+v after reload", s)
+			}
+			if nation, _ := d.GetNationality(context.Background(), "dmitrii"); len(nation.Country) != 0 {
+				t.Errorf("nationality = %+v, want none in the new file", nation.Country)
+			}
+		})
+	}
+}
+
+// TestDatasetReloadConcurrent запросы во время Reload видят старый или
+// новый индекс целиком
+func TestDatasetReloadConcurrent(t *testing.T) {
+	d, path := newTestDataset(t, datasetV1)
+	writeDataset(t, path, datasetV2)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 500 {
+				age, _ := d.GetAge(context.Background(), "dmitrii", "")
+				if age.Age != 43 && age.Age != 50 {
+					t.Errorf("age = %d during reload", age.Age)
+					return
+				}
+			}
+		}()
+	}
+	for range 20 {
+		if err := d.Reload(); err != nil {
+			t.Errorf("Reload: %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestOpenDatasetShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "names.csv")
+	writeDataset(t, path, datasetV1)
+	a, err := openDataset(path, nil)
+	if err != nil {
+		t.Fatalf("openDataset: %v", err)
+	}
+	b, _ := openDataset(path, nil)
+	if a != b {
+		t.Error("two datasets for one file")
+	}
+}
@@ -0,0 +1,123 @@
+package addition
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"future_today/internal/cerrors"
+	"future_today/internal/config"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy экспоненциальные повторы с джиттером для идемпотентных GET
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+func RetryPolicyFromConfig(cfg *config.Config) RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: cfg.RetryMax,
+		BaseDelay:  cfg.RetryBaseDelay,
+		MaxDelay:   cfg.RetryMaxDelay,
+	}
+}
+
+// backoff full jitter: случайная задержка в [0, min(max, base*2^attempt))
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseDelay << attempt
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+// statusError ответ провайдера с кодом не 2xx
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.code)
+}
+
+func retryable(err error) bool {
+	// квота не вернется за время повторов
+	if errors.Is(err, cerrors.ErrQuotaExhausted) {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500
+	}
+	// отмена запроса вызывающим не повторяется, таймаут попытки - повторяется
+	return !errors.Is(err, context.Canceled)
+}
+
+// fetcher http-клиент провайдера с таймаутом на попытку, повторами
+// и учетом квоты провайдера
+type fetcher struct {
+	client  *http.Client
+	timeout time.Duration
+	retry   RetryPolicy
+	limiter *RateLimiter
+}
+
+func newFetcher(timeout time.Duration, retry RetryPolicy, limiter *RateLimiter) *fetcher {
+	return &fetcher{client: &http.Client{}, timeout: timeout, retry: retry, limiter: limiter}
+}
+
+// getJSON cost - сколько имен в запросе, столько квоты он расходует
+func (f *fetcher) getJSON(ctx context.Context, url string, cost int, out any) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = f.try(ctx, url, cost, out)
+		if err == nil || attempt >= f.retry.MaxRetries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(f.retry.backoff(attempt)):
+		}
+	}
+}
+
+func (f *fetcher) try(ctx context.Context, url string, cost int, out any) error {
+	if err := f.limiter.Wait(ctx, cost); err != nil {
+		return err
+	}
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := f.limiter.Observe(resp); err != nil {
+		return err
+	}
+	bytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &statusError{code: resp.StatusCode}
+	}
+	return json.Unmarshal(bytes, out)
+}
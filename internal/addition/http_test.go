@@ -0,0 +1,182 @@
+package addition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"future_today/internal/cerrors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffJitter(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		limit   time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{62, time.Second}, // переполнение сдвига не дает отрицательной задержки
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempt), func(t *testing.T) {
+			seen := map[time.Duration]bool{}
+			for range 200 {
+				d := p.backoff(tt.attempt)
+				if d < 0 || d >= tt.limit {
+					t.Fatalf("backoff = %s, want in [0, %s)", d, tt.limit)
+				}
+				seen[d] = true
+			}
+			if len(seen) < 100 {
+				t.Errorf("%d distinct delays of 200, want jitter", len(seen))
+			}
+		})
+	}
+	if d := (RetryPolicy{}).backoff(3); d != 0 {
+		t.Errorf("zero policy backoff = %s, want 0", d)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&statusError{code: 500}, true},
+		{&statusError{code: 503}, true},
+		{&statusError{code: 404}, false},
+		{&QuotaError{Provider: "agify"}, false},
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), true},
+		{fmt.Errorf("get: %w", context.Canceled), false},
+		{errors.New("connection reset"), true},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// countingServer отвечает кодами statuses по очереди, дальше - последним
+func countingServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(n.Add(1)) - 1
+		status := statuses[min(i, len(statuses)-1)]
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			fmt.Fprint(w, `{"age":30}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+func TestGetJSONRetries(t *testing.T) {
+	retry := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int32
+		wantCode int
+	}{
+		{"success", []int{200}, 1, 0},
+		{"recovers after 5xx", []int{503, 500, 200}, 3, 0},
+		{"gives up after retries", []int{503}, 3, 503},
+		{"4xx not retried", []int{404, 200}, 1, 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, n := countingServer(t, tt.statuses...)
+			var out struct{ Age int }
+			err := newFetcher(time.Second, retry, nil).getJSON(context.Background(), srv.URL, 1, &out)
+			var se *statusError
+			switch {
+			case tt.wantCode == 0 && (err != nil || out.Age != 30):
+				t.Errorf("getJSON = %v, age %d", err, out.Age)
+			case tt.wantCode != 0 && (!errors.As(err, &se) || se.code != tt.wantCode):
+				t.Errorf("getJSON error = %v, want status %d", err, tt.wantCode)
+			}
+			if got := n.Load(); got != tt.requests {
+				t.Errorf("%d requests, want %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+// TestGetJSONAttemptTimeout таймаут попытки повторяется, а не обрывает вызов
+func TestGetJSONAttemptTimeout(t *testing.T) {
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n.Add(1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, `{"age":30}`)
+	}))
+	defer srv.Close()
+	retry := RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond}
+	var out struct{ Age int }
+	if err := newFetcher(50*time.Millisecond, retry, nil).getJSON(context.Background(), srv.URL, 1, &out); err != nil || out.Age != 30 {
+		t.Errorf("getJSON = %v, age %d", err, out.Age)
+	}
+}
+
+func TestGetJSONCancel(t *testing.T) {
+	tests := []struct {
+		name string
+		// hang - сервер не отвечает до отмены, иначе 503 и долгая пауза между попытками
+		hang bool
+	}{
+		{"during request", true},
+		{"during backoff", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n.Add(1)
+				if tt.hang {
+					<-r.Context().Done()
+					return
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			retry := RetryPolicy{MaxRetries: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+			start := time.Now()
+			var out struct{ Age int }
+			err := newFetcher(0, retry, nil).getJSON(ctx, srv.URL, 1, &out)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("getJSON error = %v, want context.Canceled", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("getJSON returned after %s", elapsed)
+			}
+			if got := n.Load(); got != 1 {
+				t.Errorf("%d requests, want 1", got)
+			}
+		})
+	}
+}
+
+// TestGetJSONQuotaNotRetried 429 сразу возвращает QuotaError
+func TestGetJSONQuotaNotRetried(t *testing.T) {
+	srv, n := countingServer(t, http.StatusTooManyRequests)
+	retry := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}
+	err := newFetcher(time.Second, retry, nil).getJSON(context.Background(), srv.URL, 1, &struct{}{})
+	if !errors.Is(err, cerrors.ErrQuotaExhausted) || n.Load() != 1 {
+		t.Errorf("getJSON error = %v after %d requests, want quota error after 1", err, n.Load())
+	}
+}
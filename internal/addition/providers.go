@@ -0,0 +1,141 @@
+package addition
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// AgeProvider и GenderProvider принимают страну, к которой привязать
+// ответ, пустая страна - глобальный ответ
+type AgeProvider interface {
+	GetAge(ctx context.Context, name, countryID string) (AgeEstimate, error)
+}
+
+type GenderProvider interface {
+	GetGender(ctx context.Context, name, countryID string) (GenderEstimate, error)
+}
+
+type NationalityProvider interface {
+	GetNationality(ctx context.Context, name string) (NationalityEstimate, error)
+}
+
+// AgeEstimate возраст и размер выборки, по которой он посчитан
+type AgeEstimate struct {
+	Age   int `json:"age"`
+	Count int `json:"count"`
+}
+
+type GenderEstimate struct {
+	Gender      string  `json:"gender"`
+	Probability float64 `json:"probability"`
+	Count       int     `json:"count"`
+}
+
+type CountryProbability struct {
+	CountryID   string  `json:"country_id"`
+	Probability float64 `json:"probability"`
+}
+
+// NationalityEstimate страны по убыванию вероятности
+type NationalityEstimate struct {
+	Country []CountryProbability `json:"country"`
+}
+
+func (n NationalityEstimate) sortCountries() {
+	sort.SliceStable(n.Country, func(i, j int) bool {
+		return n.Country[i].Probability > n.Country[j].Probability
+	})
+}
+
+// Top самая вероятная страна, пустая если список пуст
+func (n NationalityEstimate) Top() CountryProbability {
+	if len(n.Country) == 0 {
+		return CountryProbability{}
+	}
+	return n.Country[0]
+}
+
+// agify.io
+type Agify struct {
+	http *fetcher
+	url  string
+}
+
+func NewAgify(url string, timeout time.Duration, retry RetryPolicy, limiter *RateLimiter) *Agify {
+	return &Agify{http: newFetcher(timeout, retry, limiter), url: url}
+}
+
+func (a *Agify) GetAge(ctx context.Context, name, countryID string) (AgeEstimate, error) {
+	var age AgeEstimate
+	if err := a.http.getJSON(ctx, localizedURL(nameURL(a.url, name), countryID), 1, &age); err != nil {
+		return AgeEstimate{}, fmt.Errorf("error getting age by url: %w", err)
+	}
+	return age, nil
+}
+
+// genderize.io
+type Genderize struct {
+	http *fetcher
+	url  string
+}
+
+func NewGenderize(url string, timeout time.Duration, retry RetryPolicy, limiter *RateLimiter) *Genderize {
+	return &Genderize{http: newFetcher(timeout, retry, limiter), url: url}
+}
+
+func (g *Genderize) GetGender(ctx context.Context, name, countryID string) (GenderEstimate, error) {
+	var gender GenderEstimate
+	if err := g.http.getJSON(ctx, localizedURL(nameURL(g.url, name), countryID), 1, &gender); err != nil {
+		return GenderEstimate{}, fmt.Errorf("error getting gender by url: %w", err)
+	}
+	return gender, nil
+}
+
+// nationalize.io
+type Nationalize struct {
+	http *fetcher
+	url  string
+}
+
+func NewNationalize(url string, timeout time.Duration, retry RetryPolicy, limiter *RateLimiter) *Nationalize {
+	return &Nationalize{http: newFetcher(timeout, retry, limiter), url: url}
+}
+
+func (n *Nationalize) GetNationality(ctx context.Context, name string) (NationalityEstimate, error) {
+	var nation NationalityEstimate
+	if err := n.http.getJSON(ctx, nameURL(n.url, name), 1, &nation); err != nil {
+		return NationalityEstimate{}, fmt.Errorf("error getting nation by url: %w", err)
+	}
+	nation.sortCountries()
+	return nation, nil
+}
+
+func nameURL(base, name string) string {
+	return base + "/?" + url.Values{"name": {name}}.Encode()
+}
+
+// localizedURL добавляет country_id к запросу agify/genderize
+func localizedURL(u, countryID string) string {
+	if countryID == "" {
+		return u
+	}
+	return u + "&country_id=" + url.QueryEscape(countryID)
+}
+
+// заглушка, ничего не добавляет
+type Noop struct{}
+
+func (Noop) GetAge(ctx context.Context, name, countryID string) (AgeEstimate, error) {
+	return AgeEstimate{}, nil
+}
+
+func (Noop) GetGender(ctx context.Context, name, countryID string) (GenderEstimate, error) {
+	return GenderEstimate{}, nil
+}
+
+func (Noop) GetNationality(ctx context.Context, name string) (NationalityEstimate, error) {
+	return NationalityEstimate{}, nil
+}
@@ -0,0 +1,144 @@
+package addition
+
+import (
+	"context"
+	"fmt"
+	"future_today/internal/cerrors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// QuotaError квота провайдера исчерпана, Reset - через сколько она обновится
+type QuotaError struct {
+	Provider string
+	Reset    time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: %s, resets in %s", cerrors.ErrQuotaExhausted, e.Provider, e.Reset.Round(time.Second))
+}
+
+func (e *QuotaError) Unwrap() error {
+	return cerrors.ErrQuotaExhausted
+}
+
+// defaultQuotaReset если провайдер ответил 429 без заголовков
+const defaultQuotaReset = time.Minute
+
+// RateLimiter клиентский token bucket провайдера. Локальный лимит задается
+// в конфиге, а остаток квоты уточняется по заголовкам X-Rate-Limit-*.
+// Если токенов не хватит в пределах maxWait, вызов не ждет и получает QuotaError
+type RateLimiter struct {
+	mu       sync.Mutex
+	provider string
+	maxWait  time.Duration
+
+	capacity float64 // 0 - без локального лимита
+	rate     float64 // токенов в секунду
+	tokens   float64
+	last     time.Time
+
+	remaining int // остаток по заголовкам, -1 если неизвестен
+	resetAt   time.Time
+
+	// now подменяется в тестах
+	now func() time.Time
+}
+
+func NewRateLimiter(provider string, limit int, period, maxWait time.Duration) *RateLimiter {
+	l := &RateLimiter{provider: provider, maxWait: maxWait, remaining: -1, last: time.Now(), now: time.Now}
+	if limit > 0 && period > 0 {
+		l.capacity = float64(limit)
+		l.rate = float64(limit) / period.Seconds()
+		l.tokens = l.capacity
+	}
+	return l
+}
+
+// Wait резервирует cost токенов, при нехватке ждет их не дольше maxWait
+func (l *RateLimiter) Wait(ctx context.Context, cost int) error {
+	if l == nil {
+		return nil
+	}
+	for {
+		wait := l.reserve(float64(cost))
+		if wait == 0 {
+			return nil
+		}
+		if wait > l.maxWait {
+			return &QuotaError{Provider: l.provider, Reset: wait}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reserve 0 если токены списаны, иначе сколько ждать
+func (l *RateLimiter) reserve(cost float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	if l.remaining >= 0 {
+		if !now.Before(l.resetAt) {
+			l.remaining = -1
+		} else if float64(l.remaining) < cost {
+			return l.resetAt.Sub(now)
+		}
+	}
+
+	if l.capacity > 0 {
+		l.tokens = min(l.capacity, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		// запрос дороже емкости ждет полного ведра, иначе не пройдет никогда
+		need := min(cost, l.capacity)
+		if l.tokens < need {
+			return time.Duration((need - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.tokens -= need
+	}
+	if l.remaining >= 0 {
+		l.remaining -= int(cost)
+	}
+	return 0
+}
+
+// Observe обновляет квоту по ответу провайдера, для 429 возвращает QuotaError
+func (l *RateLimiter) Observe(resp *http.Response) error {
+	if l == nil {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return &QuotaError{Reset: headerSeconds(resp, "Retry-After", defaultQuotaReset)}
+		}
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reset := headerSeconds(resp, "X-Rate-Limit-Reset", 0)
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Remaining")); err == nil && reset > 0 {
+		l.remaining = remaining
+		l.resetAt = l.now().Add(reset)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	if reset == 0 {
+		reset = headerSeconds(resp, "Retry-After", defaultQuotaReset)
+	}
+	l.remaining = 0
+	l.resetAt = l.now().Add(reset)
+	return &QuotaError{Provider: l.provider, Reset: reset}
+}
+
+func headerSeconds(resp *http.Response, key string, def time.Duration) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get(key))
+	if err != nil || secs <= 0 {
+		return def
+	}
+	return time.Duration(secs) * time.Second
+}
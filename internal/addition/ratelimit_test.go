@@ -0,0 +1,161 @@
+package addition
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestLimiter лимит 10 токенов в минуту на часах теста
+func newTestLimiter(maxWait time.Duration) (*RateLimiter, *testClock) {
+	clock := &testClock{t: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewRateLimiter("agify", 10, time.Minute, maxWait)
+	l.now, l.last = clock.now, clock.t
+	return l, clock
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	type step struct {
+		elapsed time.Duration
+		cost    float64
+		wait    time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"full bucket", []step{{0, 10, 0}, {0, 1, 6 * time.Second}}},
+		{"refill over time", []step{{0, 10, 0}, {12 * time.Second, 2, 0}, {0, 1, 6 * time.Second}}},
+		{"partial refill", []step{{0, 10, 0}, {3 * time.Second, 1, 3 * time.Second}}},
+		{"bucket doesn't overflow", []step{{0, 4, 0}, {time.Hour, 10, 0}, {0, 1, 6 * time.Second}}},
+		// дороже емкости - ждет полного ведра
+		{"cost above capacity", []step{{0, 5, 0}, {0, 25, 30 * time.Second}, {30 * time.Second, 25, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, clock := newTestLimiter(time.Minute)
+			for i, s := range tt.steps {
+				clock.t = clock.t.Add(s.elapsed)
+				if wait := l.reserve(s.cost); wait != s.wait {
+					t.Fatalf("step %d: reserve(%v) = %s, want %s", i, s.cost, wait, s.wait)
+				}
+			}
+		})
+	}
+}
+
+// quotaResponse ответ провайдера с заголовками квоты
+func quotaResponse(status, remaining, reset int) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: http.Header{}}
+	if remaining >= 0 {
+		resp.Header.Set("X-Rate-Limit-Remaining", strconv.Itoa(remaining))
+		resp.Header.Set("X-Rate-Limit-Reset", strconv.Itoa(reset))
+	}
+	return resp
+}
+
+func TestRateLimiterRemainingHeader(t *testing.T) {
+	l := NewRateLimiter("agify", 0, 0, time.Minute)
+	clock := &testClock{t: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	l.now = clock.now
+	if err := l.Observe(quotaResponse(http.StatusOK, 30, 60)); err != nil {
+		t.Fatalf("Observe: %v", err)
+	}
+
+	tests := []struct {
+		cost      float64
+		wait      time.Duration
+		remaining int
+	}{
+		{10, 0, 20},
+		{15, 0, 5},
+		{6, time.Minute, 5},
+		{5, 0, 0},
+	}
+	for i, tt := range tests {
+		if wait := l.reserve(tt.cost); wait != tt.wait || l.remaining != tt.remaining {
+			t.Fatalf("step %d: reserve(%v) = %s, remaining %d, want %s, %d", i, tt.cost, wait, l.remaining, tt.wait, tt.remaining)
+		}
+	}
+	// после сброса окна остаток неизвестен и не ограничивает
+	clock.t = clock.t.Add(time.Minute)
+	if wait := l.reserve(100); wait != 0 || l.remaining != -1 {
+		t.Errorf("after reset: wait %s, remaining %d", wait, l.remaining)
+	}
+}
+
+// TestRateLimiterRemainingRealCost запрос дороже локальной емкости
+// расходует из квоты провайдера полную стоимость
+func TestRateLimiterRemainingRealCost(t *testing.T) {
+	l, _ := newTestLimiter(time.Minute)
+	if err := l.Observe(quotaResponse(http.StatusOK, 100, 60)); err != nil {
+		t.Fatalf("Observe: %v", err)
+	}
+	if wait := l.reserve(25); wait != 0 {
+		t.Fatalf("reserve(25) = %s, want 0", wait)
+	}
+	if l.remaining != 75 {
+		t.Errorf("remaining = %d, want 75", l.remaining)
+	}
+}
+
+func TestRateLimiterQuotaError(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(l *RateLimiter)
+		reset time.Duration
+	}{
+		{"local bucket empty", func(l *RateLimiter) { l.reserve(10) }, 6 * time.Second},
+		{"remaining header exhausted", func(l *RateLimiter) { l.Observe(quotaResponse(http.StatusOK, 0, 40)) }, 40 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := newTestLimiter(time.Second)
+			tt.setup(l)
+			err := l.Wait(context.Background(), 1)
+			var qe *QuotaError
+			if !errors.As(err, &qe) || qe.Provider != "agify" || qe.Reset != tt.reset {
+				t.Errorf("Wait error = %v, want QuotaError resetting in %s", err, tt.reset)
+			}
+		})
+	}
+}
+
+func TestRateLimiterObserve429(t *testing.T) {
+	tests := []struct {
+		name  string
+		resp  *http.Response
+		reset time.Duration
+	}{
+		{"reset header", quotaResponse(http.StatusTooManyRequests, 0, 30), 30 * time.Second},
+		{"retry-after", &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}}, 7 * time.Second},
+		{"no headers", quotaResponse(http.StatusTooManyRequests, -1, 0), defaultQuotaReset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := newTestLimiter(time.Second)
+			var qe *QuotaError
+			if err := l.Observe(tt.resp); !errors.As(err, &qe) || qe.Reset != tt.reset {
+				t.Fatalf("Observe error = %v, want reset %s", err, tt.reset)
+			}
+			// до сброса следующий вызов не идет к провайдеру
+			if wait := l.reserve(1); wait != tt.reset {
+				t.Errorf("reserve after 429 = %s, want %s", wait, tt.reset)
+			}
+		})
+	}
+}
+
+func TestRateLimiterNil(t *testing.T) {
+	var l *RateLimiter
+	if err := l.Wait(context.Background(), 100); err != nil {
+		t.Errorf("nil Wait = %v", err)
+	}
+	var qe *QuotaError
+	if err := l.Observe(quotaResponse(http.StatusTooManyRequests, -1, 0)); !errors.As(err, &qe) {
+		t.Errorf("nil Observe(429) = %v, want QuotaError", err)
+	}
+}
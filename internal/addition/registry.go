@@ -0,0 +1,110 @@
+package addition
+
+import (
+	"fmt"
+	"future_today/internal/cerrors"
+	"future_today/internal/config"
+	"future_today/internal/normalize"
+	"sort"
+	"sync"
+)
+
+// Factory создает провайдер по конфигу. Провайдер может реализовывать
+// любой набор из AgeProvider, GenderProvider и NationalityProvider
+type Factory func(cfg *config.Config) (any, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+func init() {
+	Register("agify", func(cfg *config.Config) (any, error) {
+		return NewAgify(cfg.AgifyURL, cfg.AgifyTimeout, RetryPolicyFromConfig(cfg), rateLimiterFromConfig("agify", cfg)), nil
+	})
+	Register("genderize", func(cfg *config.Config) (any, error) {
+		return NewGenderize(cfg.GenderizeURL, cfg.GenderizeTimeout, RetryPolicyFromConfig(cfg), rateLimiterFromConfig("genderize", cfg)), nil
+	})
+	Register("nationalize", func(cfg *config.Config) (any, error) {
+		return NewNationalize(cfg.NationalizeURL, cfg.NationalizeTimeout, RetryPolicyFromConfig(cfg), rateLimiterFromConfig("nationalize", cfg)), nil
+	})
+	Register("dataset", func(cfg *config.Config) (any, error) {
+		normalizer, err := normalize.New(cfg.Transliteration)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", cerrors.ErrInvalidConfig, err)
+		}
+		return openDataset(cfg.DatasetPath, normalizer)
+	})
+	Register("none", func(cfg *config.Config) (any, error) {
+		return Noop{}, nil
+	})
+}
+
+func rateLimiterFromConfig(provider string, cfg *config.Config) *RateLimiter {
+	return NewRateLimiter(provider, cfg.RateLimit, cfg.RatePeriod, cfg.QuotaMaxWait)
+}
+
+// Register добавляет провайдер в реестр, повторная регистрация заменяет старый
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Providers возвращает имена зарегистрированных провайдеров
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newProvider(name string, cfg *config.Config) (any, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", cerrors.ErrUnknownProvider, name)
+	}
+	return factory(cfg)
+}
+
+func NewAgeProvider(name string, cfg *config.Config) (AgeProvider, error) {
+	p, err := newProvider(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	age, ok := p.(AgeProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q can't provide age", cerrors.ErrProviderCapability, name)
+	}
+	return age, nil
+}
+
+func NewGenderProvider(name string, cfg *config.Config) (GenderProvider, error) {
+	p, err := newProvider(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	gender, ok := p.(GenderProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q can't provide gender", cerrors.ErrProviderCapability, name)
+	}
+	return gender, nil
+}
+
+func NewNationalityProvider(name string, cfg *config.Config) (NationalityProvider, error) {
+	p, err := newProvider(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	nation, ok := p.(NationalityProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q can't provide nationality", cerrors.ErrProviderCapability, name)
+	}
+	return nation, nil
+}
@@ -5,7 +5,21 @@ import (
 )
 
 var (
-	ErrDbConnect = errors.New("error connecting to database")
-	ErrMigration = errors.New("error during migration")
-	ErrLoadEnv   = errors.New("error loading .env file")
+	ErrDbConnect      = errors.New("error connecting to database")
+	ErrMigration      = errors.New("error during migration")
+	ErrLoadEnv        = errors.New("error loading .env file")
+	ErrInvalidConfig  = errors.New("invalid config value")
+	ErrNotFound       = errors.New("record not found")
+	ErrInvalidSort    = errors.New("invalid sort field")
+	ErrInvalidCursor  = errors.New("invalid pagination cursor")
+	ErrInvalidFilter  = errors.New("invalid filter expression")
+	ErrInvalidGroupBy = errors.New("invalid stats grouping")
+	ErrInvalidImport  = errors.New("invalid import file")
+	ErrFilterRequired = errors.New("filter is required, pass all=true to select every person")
+	ErrTooManyPersons = errors.New("too many persons")
+
+	ErrUnknownProvider    = errors.New("unknown enrichment provider")
+	ErrProviderCapability = errors.New("provider doesn't support capability")
+	ErrBreakerOpen        = errors.New("enrichment provider circuit breaker is open")
+	ErrQuotaExhausted     = errors.New("enrichment provider quota exhausted")
 )
@@ -1,13 +1,19 @@
 package config
 
 import (
+	"fmt"
 	"future_today/internal/cerrors"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
+	DbDriver       string
+	DbPath         string
+	DbAutoMigrate  bool
 	DbHost         string
 	DbPort         string
 	DbUser         string
@@ -17,6 +23,45 @@ type Config struct {
 	GenderizeURL   string
 	NationalizeURL string
 	ServerPort     string
+
+	AgeProvider         string
+	GenderProvider      string
+	NationalityProvider string
+
+	CacheSize int
+	CacheTTL  time.Duration
+
+	DefaultCountry  string
+	MinLocalCount   int
+	Transliteration string
+	DatasetPath     string
+
+	AgifyTimeout       time.Duration
+	GenderizeTimeout   time.Duration
+	NationalizeTimeout time.Duration
+	RetryMax           int
+	RetryBaseDelay     time.Duration
+	RetryMaxDelay      time.Duration
+	RateLimit          int
+	RatePeriod         time.Duration
+	QuotaMaxWait       time.Duration
+
+	BreakerThreshold   int
+	BreakerOpenTimeout time.Duration
+	ReconcileInterval  time.Duration
+	ReconcileBatch     int
+
+	JobWorkers      int
+	JobBatch        int
+	JobPollInterval time.Duration
+	JobLease        time.Duration
+	JobMaxAttempts  int
+
+	RefreshInterval time.Duration
+	RefreshMaxAge   time.Duration
+	RefreshBatch    int
+
+	ImportBatchSize int
 }
 
 func GetConfig() (*Config, error) {
@@ -24,8 +69,14 @@ func GetConfig() (*Config, error) {
 	if err != nil {
 		return nil, cerrors.ErrLoadEnv
 	}
-	return &Config{
-		ServerPort:     os.Getenv("SERVER_PORT"),
+	env := &envReader{}
+	cfg := &Config{
+		ServerPort: os.Getenv("SERVER_PORT"),
+		// postgres или sqlite, для sqlite база в файле DB_PATH
+		DbDriver: env.str("DB_DRIVER", "postgres"),
+		DbPath:   env.str("DB_PATH", "persondb.sqlite"),
+		// AutoMigrate вместо SQL-миграций, только для разработки
+		DbAutoMigrate:  env.boolean("DB_AUTO_MIGRATE", false),
 		DbHost:         os.Getenv("DB_HOST"),
 		DbPort:         os.Getenv("DB_PORT"),
 		DbUser:         os.Getenv("DB_USER"),
@@ -34,6 +85,115 @@ func GetConfig() (*Config, error) {
 		AgifyURL:       os.Getenv("API_AGIFY_URL"),
 		GenderizeURL:   os.Getenv("API_GENDERIZE_URL"),
 		NationalizeURL: os.Getenv("API_NATIONALIZE_URL"),
-	}, nil
 
+		AgeProvider:         env.str("ENRICH_AGE_PROVIDER", "agify"),
+		GenderProvider:      env.str("ENRICH_GENDER_PROVIDER", "genderize"),
+		NationalityProvider: env.str("ENRICH_NATIONALITY_PROVIDER", "nationalize"),
+
+		CacheSize: env.integer("ENRICH_CACHE_SIZE", 1000),
+		CacheTTL:  env.duration("ENRICH_CACHE_TTL", 30*24*time.Hour),
+
+		DefaultCountry: env.str("ENRICH_DEFAULT_COUNTRY", ""),
+		MinLocalCount:  env.integer("ENRICH_MIN_LOCAL_COUNT", 100),
+		// none, gost или icao
+		Transliteration: env.str("ENRICH_TRANSLITERATION", "icao"),
+		// CSV для офлайн-провайдера dataset
+		DatasetPath: env.str("ENRICH_DATASET_PATH", "data/names.csv"),
+
+		AgifyTimeout:       env.duration("API_AGIFY_TIMEOUT", 5*time.Second),
+		GenderizeTimeout:   env.duration("API_GENDERIZE_TIMEOUT", 5*time.Second),
+		NationalizeTimeout: env.duration("API_NATIONALIZE_TIMEOUT", 5*time.Second),
+		RetryMax:           env.integer("ENRICH_RETRY_MAX", 2),
+		RetryBaseDelay:     env.duration("ENRICH_RETRY_BASE_DELAY", 200*time.Millisecond),
+		RetryMaxDelay:      env.duration("ENRICH_RETRY_MAX_DELAY", 2*time.Second),
+		RateLimit:          env.integer("ENRICH_RATE_LIMIT", 0),
+		RatePeriod:         env.duration("ENRICH_RATE_PERIOD", 24*time.Hour),
+		QuotaMaxWait:       env.duration("ENRICH_QUOTA_MAX_WAIT", 5*time.Second),
+
+		BreakerThreshold:   env.integer("ENRICH_BREAKER_THRESHOLD", 5),
+		BreakerOpenTimeout: env.duration("ENRICH_BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		ReconcileInterval:  env.duration("ENRICH_RECONCILE_INTERVAL", time.Minute),
+		ReconcileBatch:     env.integer("ENRICH_RECONCILE_BATCH", 100),
+
+		JobWorkers:      env.integer("ENRICH_JOB_WORKERS", 4),
+		JobBatch:        env.integer("ENRICH_JOB_BATCH", 10),
+		JobPollInterval: env.duration("ENRICH_JOB_POLL_INTERVAL", time.Second),
+		JobLease:        env.duration("ENRICH_JOB_LEASE", 2*time.Minute),
+		JobMaxAttempts:  env.integer("ENRICH_JOB_MAX_ATTEMPTS", 5),
+
+		RefreshInterval: env.duration("ENRICH_REFRESH_INTERVAL", time.Hour),
+		RefreshMaxAge:   env.duration("ENRICH_REFRESH_MAX_AGE", 90*24*time.Hour),
+		RefreshBatch:    env.integer("ENRICH_REFRESH_BATCH", 500),
+
+		// строк импорта в одной транзакции
+		ImportBatchSize: env.integer("IMPORT_BATCH_SIZE", 500),
+	}
+	if env.err != nil {
+		return nil, env.err
+	}
+	return cfg, nil
+
+}
+
+// envReader читает необязательные переменные со значением по умолчанию,
+// запоминая первую ошибку разбора
+type envReader struct {
+	err error
+}
+
+func (r *envReader) lookup(key string) (string, bool) {
+	v, ok := os.LookupEnv(key)
+	return v, ok && v != ""
+}
+
+func (r *envReader) str(key, def string) string {
+	if v, ok := r.lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+func (r *envReader) integer(key string, def int) int {
+	v, ok := r.lookup(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		r.fail(key, err)
+		return def
+	}
+	return n
+}
+
+func (r *envReader) boolean(key string, def bool) bool {
+	v, ok := r.lookup(key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		r.fail(key, err)
+		return def
+	}
+	return b
+}
+
+func (r *envReader) duration(key string, def time.Duration) time.Duration {
+	v, ok := r.lookup(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		r.fail(key, err)
+		return def
+	}
+	return d
+}
+
+func (r *envReader) fail(key string, err error) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%w: %s: %v", cerrors.ErrInvalidConfig, key, err)
+	}
 }
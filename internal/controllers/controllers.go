@@ -1,12 +1,21 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"future_today/internal/addition"
+	"future_today/internal/cerrors"
+	"future_today/internal/filterexpr"
+	"future_today/internal/search"
 	"future_today/models"
 	services "future_today/services"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,43 +31,222 @@ func NewPersonController(service *services.PersonService, logger *logrus.Logger)
 	}
 }
 
+const includeEnrichmentDetails = "enrichment_details"
+
+// includes проверяет, запрошен ли блок в ?include=a,b
+func includes(ctx *gin.Context, block string) bool {
+	for _, v := range strings.Split(ctx.Query("include"), ",") {
+		if strings.TrimSpace(v) == block {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePersonFilter общие фильтры списка, некорректные значения игнорируются
+func parsePersonFilter(ctx *gin.Context) (*models.PersonFilter, error) {
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	expr, err := filterexpr.Parse(ctx.Query("filter"), models.PersonFilterFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.PersonFilter{
+		Name:                      queryString(ctx, "name"),
+		Surname:                   queryString(ctx, "surname"),
+		Patronymic:                queryString(ctx, "patronymic"),
+		Gender:                    queryString(ctx, "gender"),
+		Nationality:               queryString(ctx, "nation"),
+		MinAge:                    queryInt(ctx, "min_age"),
+		MaxAge:                    queryInt(ctx, "max_age"),
+		MinGenderProbability:      queryFloat(ctx, "min_gender_probability"),
+		MinNationalityProbability: queryFloat(ctx, "min_nationality_probability"),
+		Expr:                      expr,
+		Limit:                     limit,
+		Offset:                    offset,
+	}, nil
+}
+
+// filterErrorResponse тело 400 для ошибки разбора filter с позицией ошибки
+func filterErrorResponse(err error) gin.H {
+	response := gin.H{"error": err.Error()}
+	var ferr *filterexpr.Error
+	if errors.As(err, &ferr) {
+		response["error"] = cerrors.ErrInvalidFilter.Error() + ": " + ferr.Error()
+		response["position"] = ferr.Pos
+	}
+	return response
+}
+
+// parseSort разбирает "surname,-age": минус - по убыванию. Допустимость
+// полей проверяет хранилище
+func parseSort(raw string) []models.SortField {
+	var sort []models.SortField
+	for _, part := range splitList(raw) {
+		sort = append(sort, models.SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")})
+	}
+	return sort
+}
+
+// splitList значения списка через запятую без пустых
+func splitList(raw string) []string {
+	var values []string
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// pageLink ссылка на текущий запрос с замененными параметрами
+func pageLink(ctx *gin.Context, params map[string]string) string {
+	query := ctx.Request.URL.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	return ctx.Request.URL.Path + "?" + query.Encode()
+}
+
+func queryString(ctx *gin.Context, key string) *string {
+	v := ctx.Query(key)
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+func queryInt(ctx *gin.Context, key string) *int {
+	v, err := strconv.Atoi(ctx.Query(key))
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func queryFloat(ctx *gin.Context, key string) *float64 {
+	v, err := strconv.ParseFloat(ctx.Query(key), 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// enrichmentErrorStatus 429 с Retry-After, если у провайдера кончилась квота
+func enrichmentErrorStatus(ctx *gin.Context, err error) int {
+	var qe *addition.QuotaError
+	if errors.As(err, &qe) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(qe.Reset.Seconds()))))
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+func newPersonResponse(person *models.Person, details bool) models.PersonResponse {
+	response := models.PersonResponse{
+		ID:          person.ID,
+		Name:        person.Name,
+		Surname:     person.Surname,
+		Patronymic:  person.Patronymic,
+		CountryHint: person.CountryHint,
+		Age:         person.Age,
+		Gender:      person.Gender,
+		Nationality: person.Nationality,
+		IsActive:    person.IsActive,
+
+		EnrichmentPending: person.EnrichmentPending,
+		EnrichedAt:        person.EnrichedAt,
+	}
+	if len(person.Provenance) > 0 {
+		response.Provenance = make(map[string]models.ProvenanceResponse, len(person.Provenance))
+		for _, fp := range person.Provenance {
+			response.Provenance[fp.Field] = models.ProvenanceResponse{
+				Source:     fp.Source,
+				Provider:   fp.Provider,
+				Confidence: fp.Confidence,
+				UpdatedAt:  fp.UpdatedAt,
+			}
+		}
+	}
+	if details {
+		countries := make([]models.CountryProbability, len(person.Countries))
+		for i, c := range person.Countries {
+			countries[i] = models.CountryProbability{CountryID: c.CountryID, Probability: c.Probability}
+		}
+		response.EnrichmentDetails = &models.EnrichmentDetails{
+			AgeCount:               person.AgeCount,
+			GenderProbability:      person.GenderProbability,
+			NationalityProbability: person.NationalityProbability,
+			Countries:              countries,
+		}
+	}
+	return response
+}
+
 // @Summary Create a new person
 // @Description Create a new person with the input payload
 // @Tags persons
 // @Accept  json
 // @Produce  json
 // @Param person body models.CreatePersonRequest true "Create person"
+// @Param include query string false "Extra blocks: enrichment_details"
+// @Param async query bool false "Enrich in background and return 202 with job ID"
 // @Success 200 {object} models.PersonResponse
+// @Success 202 {object} models.CreatePersonAsyncResponse
 // @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /persons [post]
 func (c *PersonController) CreatePerson(ctx *gin.Context) {
 	var req models.CreatePersonRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
+	if err := bindCreatePerson(ctx, &req); err != nil {
 		c.logger.Errorf("Error binding JSON: %v", err)
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	person, err := c.service.CreatePerson(&req)
+	if async, _ := strconv.ParseBool(ctx.Query("async")); async {
+		c.createPersonAsync(ctx, &req)
+		return
+	}
+	person, err := c.service.CreatePerson(ctx.Request.Context(), &req)
 	if err != nil {
 		c.logger.Errorf("Error creating person: %v", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(enrichmentErrorStatus(ctx, err), gin.H{"error": err.Error()})
 		return
 	}
-	response := models.PersonResponse{
-		ID:          person.ID,
-		Name:        person.Name,
-		Surname:     person.Surname,
-		Patronymic:  person.Patronymic,
-		Age:         person.Age,
-		Gender:      person.Gender,
-		Nationality: person.Nationality,
-		IsActive:    person.IsActive,
-	}
+	response := newPersonResponse(person, includes(ctx, includeEnrichmentDetails))
 	c.logger.Info("Person created sucessfully")
 	ctx.JSON(http.StatusOK, response)
 }
 
+// bindCreatePerson разбирает тело запроса и проверяет его после Normalize
+func bindCreatePerson(ctx *gin.Context, req *models.CreatePersonRequest) error {
+	if ctx.Request.Body == nil {
+		return errors.New("invalid request")
+	}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(req); err != nil {
+		return err
+	}
+	req.Normalize()
+	return binding.Validator.ValidateStruct(req)
+}
+
+func (c *PersonController) createPersonAsync(ctx *gin.Context, req *models.CreatePersonRequest) {
+	person, job, err := c.service.CreatePersonAsync(req)
+	if err != nil {
+		c.logger.Errorf("Error creating person: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response := models.CreatePersonAsyncResponse{
+		JobID:  job.ID,
+		Person: newPersonResponse(person, false),
+	}
+	c.logger.Infof("Person created, enrichment job %d queued", job.ID)
+	ctx.JSON(http.StatusAccepted, response)
+}
+
 // @Summary Get all persons
 // @Description Get all persons with optional filters
 // @Tags persons
@@ -71,85 +259,108 @@ func (c *PersonController) CreatePerson(ctx *gin.Context) {
 // @Param min_age query int false "Minimum age filter"
 // @Param max_age query int false "Maximum age filter"
 // @Param gender query string false "Gender filter"
-// @Param nationality query string false "Nationality filter"
-// @Success 200 {array} models.PersonResponse
+// @Param nation query string false "Nationality filter"
+// @Param patronymic query string false "Patronymic filter"
+// @Param min_gender_probability query number false "Minimum gender probability filter"
+// @Param min_nationality_probability query number false "Minimum nationality probability filter"
+// @Param include query string false "Extra blocks: enrichment_details"
+// @Param sort query string false "Sort fields, comma separated, '-' for descending: surname,-age,created_at"
+// @Param cursor query string false "Cursor from next_cursor of the previous page, replaces offset"
+// @Param filter query string false "Filter expression with eq, ne, gt, ge, lt, le, contains, in, and, or, not and parentheses; strings in double quotes"
+// @Success 200 {object} models.PersonListResponse
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /persons [get]
 func (c *PersonController) GetAllPersons(ctx *gin.Context) {
-	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
-	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
-
-	name := ctx.Query("name")
-	surname := ctx.Query("surname")
-	patronymic := ctx.Query("patronymic")
-	minAgeStr := ctx.Query("min_age")
-	maxAgeStr := ctx.Query("max_age")
-	gender := ctx.Query("gender")
-	nation := ctx.Query("nation")
-
-	var minAge, maxAge *int
+	filter, err := parsePersonFilter(ctx)
+	if err != nil {
+		c.logger.Errorf("Error parsing filter: %v", err)
+		ctx.JSON(http.StatusBadRequest, filterErrorResponse(err))
+		return
+	}
+	filter.Sort = parseSort(ctx.Query("sort"))
+	filter.Cursor = ctx.Query("cursor")
 
-	if minAgeStr != "" {
-		age, err := strconv.Atoi(minAgeStr)
-		if err == nil {
-			minAge = &age
-		}
+	page, err := c.service.GetAllPersons(filter)
+	if errors.Is(err, cerrors.ErrInvalidSort) || errors.Is(err, cerrors.ErrInvalidCursor) {
+		c.logger.Errorf("Error getting persons: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if maxAgeStr != "" {
-		age, err := strconv.Atoi(maxAgeStr)
-		if err == nil {
-			maxAge = &age
-		}
+	if err != nil {
+		c.logger.Errorf("Error getting persons: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	var namePtr, surnamePtr, genderPtr, nationPtr, patronymicPtr *string
-	if name != "" {
-		namePtr = &name
+	details := includes(ctx, includeEnrichmentDetails)
+	response := models.PersonListResponse{
+		Items:      make([]models.PersonResponse, len(page.Persons)),
+		Total:      page.Total,
+		Limit:      filter.Limit,
+		Offset:     filter.Offset,
+		NextCursor: page.NextCursor,
 	}
-	if surname != "" {
-		surnamePtr = &surname
+	if filter.Cursor != "" {
+		response.Offset = 0
 	}
-	if patronymic != "" {
-		patronymicPtr = &patronymic
+	for i, person := range page.Persons {
+		response.Items[i] = newPersonResponse(&person, details)
 	}
-	if gender != "" {
-		genderPtr = &gender
+	switch {
+	case page.HasMore && filter.Cursor != "" && page.NextCursor != "":
+		response.Next = pageLink(ctx, map[string]string{"cursor": page.NextCursor})
+	case page.HasMore && filter.Cursor == "":
+		response.Next = pageLink(ctx, map[string]string{"offset": strconv.Itoa(filter.Offset + len(page.Persons))})
+	}
+	c.logger.Info("Succesfully got all persons")
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary Search persons
+// @Description Fuzzy search by name, surname and patronymic ranked by relevance. Highlight holds only fields with a word similar to a query word and is omitted when no single word matches
+// @Tags persons
+// @Accept  json
+// @Produce  json
+// @Param q query string true "Search query"
+// @Param limit query int false "Limit, default 10, max 100"
+// @Success 200 {object} models.PersonSearchResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /persons/search [get]
+func (c *PersonController) SearchPersons(ctx *gin.Context) {
+	query := strings.TrimSpace(ctx.Query("q"))
+	if query == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "query parameter q is required"})
+		return
 	}
-	if nation != "" {
-		nationPtr = &nation
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
 	}
+	limit = min(limit, 100)
 
-	persons, err := c.service.GetAllPersons(
-		limit,
-		offset,
-		namePtr,
-		surnamePtr,
-		patronymicPtr,
-		minAge,
-		maxAge,
-		genderPtr,
-		nationPtr,
-	)
+	hits, err := c.service.SearchPersons(query, limit)
 	if err != nil {
-		c.logger.Errorf("Error getting persons: %v", err)
+		c.logger.Errorf("Error searching persons: %v", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	response := make([]models.PersonResponse, len(persons))
-	for i, person := range persons {
-		response[i] = models.PersonResponse{
-			ID:          person.ID,
-			Name:        person.Name,
-			Surname:     person.Surname,
-			Patronymic:  person.Patronymic,
-			Age:         person.Age,
-			Gender:      person.Gender,
-			Nationality: person.Nationality,
-			IsActive:    person.IsActive,
+	response := models.PersonSearchResponse{Query: query, Items: make([]models.PersonSearchHit, len(hits))}
+	for i, hit := range hits {
+		item := models.PersonSearchHit{PersonResponse: newPersonResponse(&hit.Person, false), Score: hit.Score}
+		for field, text := range map[string]string{"name": hit.Person.Name, "surname": hit.Person.Surname, "patronymic": hit.Person.Patronymic} {
+			if h := search.Highlight(query, text); h != "" {
+				if item.Highlight == nil {
+					item.Highlight = map[string]string{}
+				}
+				item.Highlight[field] = h
+			}
 		}
+		response.Items[i] = item
 	}
-	c.logger.Info("Succesfully got all persons")
 	ctx.JSON(http.StatusOK, response)
 }
 
@@ -159,6 +370,7 @@ func (c *PersonController) GetAllPersons(ctx *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Person ID"
+// @Param include query string false "Extra blocks: enrichment_details"
 // @Success 200 {object} models.PersonResponse
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -178,16 +390,7 @@ func (c *PersonController) GetPerson(ctx *gin.Context) {
 		return
 	}
 
-	response := models.PersonResponse{
-		ID:          person.ID,
-		Name:        person.Name,
-		Surname:     person.Surname,
-		Patronymic:  person.Patronymic,
-		Age:         person.Age,
-		Gender:      person.Gender,
-		Nationality: person.Nationality,
-		IsActive:    person.IsActive,
-	}
+	response := newPersonResponse(person, includes(ctx, includeEnrichmentDetails))
 	c.logger.Info("Person got sucessfully")
 	ctx.JSON(http.StatusOK, response)
 }
@@ -199,6 +402,7 @@ func (c *PersonController) GetPerson(ctx *gin.Context) {
 // @Produce  json
 // @Param id path int true "Person ID"
 // @Param person body models.UpdatePersonRequest true "Update person"
+// @Param include query string false "Extra blocks: enrichment_details"
 // @Success 200 {object} models.PersonResponse
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -225,20 +429,92 @@ func (c *PersonController) UpdatePerson(ctx *gin.Context) {
 		return
 	}
 
-	response := models.PersonResponse{
-		ID:          person.ID,
-		Name:        person.Name,
-		Surname:     person.Surname,
-		Patronymic:  person.Patronymic,
-		Age:         person.Age,
-		Gender:      person.Gender,
-		Nationality: person.Nationality,
-		IsActive:    person.IsActive,
-	}
+	response := newPersonResponse(person, includes(ctx, includeEnrichmentDetails))
 	c.logger.Info("Person updated succesfully")
 	ctx.JSON(http.StatusOK, response)
 }
 
+// @Summary Re-enrich a person
+// @Description Re-run enrichment providers for a person, manually set fields are kept unless force=true
+// @Tags persons
+// @Produce  json
+// @Param id path int true "Person ID"
+// @Param force query bool false "Overwrite manually set fields"
+// @Param include query string false "Extra blocks: enrichment_details"
+// @Success 200 {object} models.PersonResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /persons/{id}/enrich [post]
+func (c *PersonController) EnrichPerson(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		c.logger.Errorf("Error parsing ID: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	force, _ := strconv.ParseBool(ctx.Query("force"))
+	person, err := c.service.ReEnrichPerson(ctx.Request.Context(), uint(id), force)
+	if errors.Is(err, cerrors.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "person not found"})
+		return
+	}
+	if err != nil {
+		c.logger.Errorf("Error enriching person: %v", err)
+		ctx.JSON(enrichmentErrorStatus(ctx, err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := newPersonResponse(person, includes(ctx, includeEnrichmentDetails))
+	c.logger.Info("Person enriched succesfully")
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary Re-enrich persons
+// @Description Queue re-enrichment of every person matching the filter, manually set fields are kept unless force=true. An empty filter needs all=true, at most 10000 persons per request
+// @Tags persons
+// @Accept  json
+// @Produce  json
+// @Param filter body models.PersonFilter false "Persons filter"
+// @Param all query bool false "Queue every person when the filter is empty"
+// @Param force query bool false "Overwrite manually set fields"
+// @Success 202 {object} models.BulkEnrichResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /enrich [post]
+func (c *PersonController) EnrichPersons(ctx *gin.Context) {
+	var filter models.PersonFilter
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&filter); err != nil {
+			c.logger.Errorf("Error binding JSON: %v", err)
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	all, _ := strconv.ParseBool(ctx.Query("all"))
+	force, _ := strconv.ParseBool(ctx.Query("force"))
+	jobs, err := c.service.EnrichPersons(&filter, all, force)
+	if errors.Is(err, cerrors.ErrFilterRequired) || errors.Is(err, cerrors.ErrTooManyPersons) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.logger.Errorf("Error queueing enrichment: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := models.BulkEnrichResponse{Queued: len(jobs), JobIDs: make([]uint, len(jobs))}
+	for i, job := range jobs {
+		response.JobIDs[i] = job.ID
+	}
+	c.logger.Infof("Queued enrichment of %d persons", len(jobs))
+	ctx.JSON(http.StatusAccepted, response)
+}
+
 // мб сделать действительное удаление ?
 
 // @Summary Delete a person
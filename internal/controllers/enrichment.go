@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"future_today/internal/addition"
+	"future_today/models"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type EnrichmentController struct {
+	add    *addition.Addition
+	cache  *addition.Cache
+	logger *logrus.Logger
+}
+
+func NewEnrichmentController(add *addition.Addition, cache *addition.Cache, logger *logrus.Logger) *EnrichmentController {
+	return &EnrichmentController{
+		add:    add,
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// @Summary Preview enrichment
+// @Description Run enrichment providers for a name without saving anything
+// @Tags enrichment
+// @Produce  json
+// @Param name query string true "Name"
+// @Param country_hint query string false "Country to localize age and gender to (ISO 3166-1 alpha-2)"
+// @Success 200 {object} models.EnrichmentPreviewResponse
+// @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /enrichment/preview [get]
+func (c *EnrichmentController) Preview(ctx *gin.Context) {
+	name := strings.TrimSpace(ctx.Query("name"))
+	if name == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	q := addition.Query{Name: name, CountryHint: strings.ToUpper(strings.TrimSpace(ctx.Query("country_hint")))}
+	res, err := c.add.Enrich(ctx.Request.Context(), q)
+	if err != nil {
+		c.logger.Errorf("Error previewing enrichment: %v", err)
+		ctx.JSON(enrichmentErrorStatus(ctx, err), gin.H{"error": err.Error()})
+		return
+	}
+
+	countries := make([]models.CountryProbability, len(res.Countries))
+	for i, country := range res.Countries {
+		countries[i] = models.CountryProbability{CountryID: country.CountryID, Probability: country.Probability}
+	}
+	response := models.EnrichmentPreviewResponse{
+		Name:                   name,
+		NormalizedName:         res.NormalizedName,
+		Age:                    res.Age,
+		AgeCount:               res.AgeCount,
+		Gender:                 res.Gender,
+		GenderProbability:      res.GenderProbability,
+		Nationality:            res.Nationality,
+		NationalityProbability: res.NationalityProbability,
+		Countries:              countries,
+		LocalizedTo:            res.Country,
+		Sources:                res.Sources,
+		Missing:                res.Missing,
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// @Summary Invalidate enrichment cache
+// @Description Drop all cached answers of an enrichment provider
+// @Tags enrichment
+// @Produce  json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /enrichment/cache/{provider} [delete]
+func (c *EnrichmentController) InvalidateCache(ctx *gin.Context) {
+	provider := ctx.Param("provider")
+	if !slices.Contains(addition.Providers(), provider) {
+		c.logger.Errorf("Unknown provider: %s", provider)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "unknown provider"})
+		return
+	}
+
+	if err := c.cache.Invalidate(provider); err != nil {
+		c.logger.Errorf("Error invalidating cache: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.logger.Infof("Cache of provider %s invalidated", provider)
+	ctx.JSON(http.StatusOK, gin.H{"message": "cache invalidated"})
+}
+
+// @Summary Reload offline dataset
+// @Description Re-read the name statistics file of the offline dataset provider and drop its cached answers
+// @Tags admin
+// @Produce  json
+// @Success 200 {object} map[string]addition.DatasetStatus
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/enrichment/dataset/reload [post]
+func (c *EnrichmentController) ReloadDataset(ctx *gin.Context) {
+	if len(c.add.Datasets()) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "dataset provider is not configured"})
+		return
+	}
+
+	statuses, err := c.add.ReloadDatasets()
+	if err != nil {
+		c.logger.Errorf("Error reloading dataset: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for provider, status := range statuses {
+		if err := c.cache.Invalidate(provider); err != nil {
+			c.logger.Errorf("Error invalidating cache: %v", err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.logger.Infof("Dataset %s reloaded: %d names", status.Path, status.Names)
+	}
+	ctx.JSON(http.StatusOK, statuses)
+}
+
+// @Summary Enrichment circuit breakers
+// @Description Get state of every enrichment provider circuit breaker
+// @Tags diagnostics
+// @Produce  json
+// @Success 200 {array} addition.BreakerStatus
+// @Router /diagnostics/breakers [get]
+func (c *EnrichmentController) GetBreakers(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.add.Breakers())
+}
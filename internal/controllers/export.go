@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"future_today/internal/cerrors"
+	"future_today/internal/export"
+	"future_today/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushRows через сколько строк принудительно отправлять выгрузку
+// клиенту, чтобы редкие строки медленного запроса не копились в буфере
+const exportFlushRows = 500
+
+// exportErrorTrailer трейлер с ошибкой, если выгрузка оборвалась
+// после отправки заголовков
+const exportErrorTrailer = "X-Export-Error"
+
+// @Summary Export persons
+// @Description Stream all persons matching the list filters as a file download. If the export breaks after the download started, the error is sent in the X-Export-Error trailer and, for ndjson and xlsx, as the last record
+// @Tags persons
+// @Produce  text/csv
+// @Produce  application/x-ndjson
+// @Produce  application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv (default), ndjson or xlsx"
+// @Param name query string false "Name filter"
+// @Param surname query string false "Surname filter"
+// @Param patronymic query string false "Patronymic filter"
+// @Param min_age query int false "Minimum age filter"
+// @Param max_age query int false "Maximum age filter"
+// @Param gender query string false "Gender filter"
+// @Param nation query string false "Nationality filter"
+// @Param filter query string false "Filter expression, same as for the list"
+// @Param sort query string false "Sort fields, same as for the list"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /persons/export [get]
+func (c *PersonController) ExportPersons(ctx *gin.Context) {
+	filter, err := parsePersonFilter(ctx)
+	if err != nil {
+		c.logger.Errorf("Error parsing filter: %v", err)
+		ctx.JSON(http.StatusBadRequest, filterErrorResponse(err))
+		return
+	}
+	filter.Sort = parseSort(ctx.Query("sort"))
+	format := ctx.DefaultQuery("format", export.CSV)
+
+	// csv, bufio и zip отдают данные в ctx.Writer по мере заполнения своих
+	// буферов (около 4 КБ). Пока ничего не отправлено, ошибку можно вернуть
+	// кодом ответа, после - только трейлером и записью в конце файла
+	w, err := export.NewWriter(format, ctx.Writer)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", export.ContentType(format))
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="persons-%s.%s"`, time.Now().UTC().Format("20060102-150405"), format))
+	header.Set("Trailer", exportErrorTrailer)
+
+	rows := 0
+	err = c.service.ExportPersons(filter, func(person *models.Person) error {
+		if err := w.Write(person); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			ctx.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		c.logger.Errorf("Error exporting persons after %d rows: %v", rows, err)
+		if ctx.Writer.Written() {
+			// заголовки уже отправлены: сообщаем об ошибке в конце ответа
+			if err := w.Fail(err); err != nil {
+				c.logger.Errorf("Error writing export error record: %v", err)
+			}
+			header.Set(exportErrorTrailer, err.Error())
+			return
+		}
+		header.Del("Content-Disposition")
+		header.Del("Content-Type")
+		header.Del("Trailer")
+		status := http.StatusInternalServerError
+		if errors.Is(err, cerrors.ErrInvalidSort) {
+			status = http.StatusBadRequest
+		}
+		ctx.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.logger.Infof("Exported %d persons as %s", rows, format)
+}
@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"future_today/internal/cerrors"
+	"future_today/internal/importer"
+	"future_today/models"
+	services "future_today/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type ImportController struct {
+	service *services.ImportService
+	logger  *logrus.Logger
+}
+
+func NewImportController(service *services.ImportService, logger *logrus.Logger) *ImportController {
+	return &ImportController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// @Summary Import persons
+// @Description Create persons from a CSV (header: name,surname,patronymic,country_hint) or NDJSON file. Rows are validated like POST /persons, saved in batches and queued for enrichment
+// @Tags imports
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param file formData file true "CSV or NDJSON file"
+// @Param format formData string false "csv or ndjson, by default from the file extension"
+// @Success 201 {object} models.ImportJobResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /persons/import [post]
+func (c *ImportController) Import(ctx *gin.Context) {
+	header, err := ctx.FormFile("file")
+	if err != nil {
+		c.logger.Errorf("Error reading import file: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "multipart field file is required"})
+		return
+	}
+	format, err := importer.DetectFormat(ctx.PostForm("format"), header.Filename)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.logger.Errorf("Error opening import file: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	job, failed, err := c.service.Import(format, header.Filename, file)
+	if errors.Is(err, cerrors.ErrInvalidImport) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.logger.Errorf("Error importing persons: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := newImportResponse(ctx, job)
+	for _, row := range failed {
+		response.Errors = append(response.Errors, newImportRowResponse(&row))
+	}
+	c.logger.Infof("Import %d: %d persons created, %d rows failed", job.ID, job.Created, job.Failed)
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// @Summary Get import
+// @Description Get status and counters of a persons import
+// @Tags imports
+// @Produce  json
+// @Param id path int true "Import ID"
+// @Success 200 {object} models.ImportJobResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /imports/{id} [get]
+func (c *ImportController) GetImport(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+	job, err := c.service.GetImport(uint(id))
+	if errors.Is(err, cerrors.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "import not found"})
+		return
+	}
+	if err != nil {
+		c.logger.Errorf("Error getting import: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, newImportResponse(ctx, job))
+}
+
+// @Summary Download import report
+// @Description Result of every row of the import: created person and enrichment job or error
+// @Tags imports
+// @Produce  text/csv
+// @Produce  application/x-ndjson
+// @Param id path int true "Import ID"
+// @Param format query string false "csv (default) or ndjson"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /imports/{id}/report [get]
+func (c *ImportController) GetImportReport(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+	format := ctx.DefaultQuery("format", importer.CSV)
+	if format != importer.CSV && format != importer.NDJSON {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown report format %q, allowed: csv, ndjson", format)})
+		return
+	}
+	job, err := c.service.GetImport(uint(id))
+	if errors.Is(err, cerrors.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "import not found"})
+		return
+	}
+	if err != nil {
+		c.logger.Errorf("Error getting import: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%d-report.%s"`, job.ID, format))
+	var write func(row *models.ImportRow) error
+	var flush func() error
+	if format == importer.CSV {
+		ctx.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(ctx.Writer)
+		w.Write([]string{"line", "status", "person_id", "enrichment_job_id", "error"})
+		write = func(row *models.ImportRow) error {
+			return w.Write([]string{strconv.Itoa(row.Line), importRowStatus(row), formatID(row.PersonID), formatID(row.EnrichmentJobID), row.Error})
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		ctx.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(ctx.Writer)
+		write = func(row *models.ImportRow) error {
+			return enc.Encode(struct {
+				models.ImportRowResponse
+				Status string `json:"status"`
+			}{newImportRowResponse(row), importRowStatus(row)})
+		}
+		flush = func() error { return nil }
+	}
+
+	err = c.service.ImportReport(job.ID, write)
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		// заголовки уже отправлены, клиент получит оборванный отчет
+		c.logger.Errorf("Error writing import %d report: %v", job.ID, err)
+	}
+}
+
+func newImportResponse(ctx *gin.Context, job *models.ImportJob) models.ImportJobResponse {
+	return models.ImportJobResponse{
+		ID:         job.ID,
+		Status:     job.Status,
+		Format:     job.Format,
+		Filename:   job.Filename,
+		Total:      job.Total,
+		Created:    job.Created,
+		Failed:     job.Failed,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: job.FinishedAt,
+		Report:     importReportLink(ctx, job.ID),
+	}
+}
+
+func newImportRowResponse(row *models.ImportRow) models.ImportRowResponse {
+	return models.ImportRowResponse{
+		Line:            row.Line,
+		PersonID:        row.PersonID,
+		EnrichmentJobID: row.EnrichmentJobID,
+		Error:           row.Error,
+	}
+}
+
+func importRowStatus(row *models.ImportRow) string {
+	if row.Error != "" {
+		return "failed"
+	}
+	return "created"
+}
+
+func formatID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+// importReportLink ссылка на отчет загрузки в той же группе маршрутов
+func importReportLink(ctx *gin.Context, id uint) string {
+	base := ctx.Request.URL.Path
+	for _, suffix := range []string{"/persons/import", "/imports/"} {
+		if i := strings.Index(base, suffix); i >= 0 {
+			base = base[:i]
+			break
+		}
+	}
+	return fmt.Sprintf("%s/imports/%d/report", base, id)
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"errors"
+	"future_today/internal/cerrors"
+	"future_today/models"
+	services "future_today/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type JobController struct {
+	service *services.PersonService
+	logger  *logrus.Logger
+}
+
+func NewJobController(service *services.PersonService, logger *logrus.Logger) *JobController {
+	return &JobController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// @Summary Get enrichment job
+// @Description Get status of a background enrichment job
+// @Tags jobs
+// @Produce  json
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.JobResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /jobs/{id} [get]
+func (c *JobController) GetJob(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		c.logger.Errorf("Error parsing ID: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	job, err := c.service.GetJob(uint(id))
+	if errors.Is(err, cerrors.ErrNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if err != nil {
+		c.logger.Errorf("Error getting job: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := models.JobResponse{
+		ID:        job.ID,
+		PersonID:  job.PersonID,
+		Status:    job.Status,
+		Attempts:  job.Attempts,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+	ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"future_today/internal/cerrors"
+	"future_today/models"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	aggCount  = "count"
+	aggAvgAge = "avg_age"
+	aggMinAge = "min_age"
+	aggMaxAge = "max_age"
+)
+
+var statsAggregates = []string{aggCount, aggAvgAge, aggMinAge, aggMaxAge}
+
+// @Summary Person statistics
+// @Description Count and age aggregates of persons grouped by gender, nationality and age buckets. Accepts the same filters as the list
+// @Tags persons
+// @Accept  json
+// @Produce  json
+// @Param group_by query string false "Comma separated: gender, nationality, age"
+// @Param age_bucket query int false "Age bucket width in years for group_by=age, default 10"
+// @Param aggregates query string false "Comma separated: count, avg_age, min_age, max_age. Default all"
+// @Param name query string false "Name filter"
+// @Param surname query string false "Surname filter"
+// @Param patronymic query string false "Patronymic filter"
+// @Param min_age query int false "Minimum age filter"
+// @Param max_age query int false "Maximum age filter"
+// @Param gender query string false "Gender filter"
+// @Param nation query string false "Nationality filter"
+// @Param filter query string false "Filter expression, same as for the list"
+// @Success 200 {object} models.PersonStatsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /persons/stats [get]
+func (c *PersonController) GetPersonStats(ctx *gin.Context) {
+	filter, err := parsePersonFilter(ctx)
+	if err != nil {
+		c.logger.Errorf("Error parsing filter: %v", err)
+		ctx.JSON(http.StatusBadRequest, filterErrorResponse(err))
+		return
+	}
+	query := models.PersonStatsQuery{GroupBy: splitList(ctx.Query("group_by"))}
+	if slices.Contains(query.GroupBy, models.GroupByAge) {
+		query.AgeBucket, err = strconv.Atoi(ctx.DefaultQuery("age_bucket", "10"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid age_bucket"})
+			return
+		}
+	}
+	aggregates := splitList(ctx.Query("aggregates"))
+	if len(aggregates) == 0 {
+		aggregates = statsAggregates
+	}
+	for _, agg := range aggregates {
+		if !slices.Contains(statsAggregates, agg) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown aggregate %q, allowed: count, avg_age, min_age, max_age", agg)})
+			return
+		}
+	}
+
+	stats, err := c.service.PersonStats(filter, query)
+	if errors.Is(err, cerrors.ErrInvalidGroupBy) {
+		c.logger.Errorf("Error getting stats: %v", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.logger.Errorf("Error getting stats: %v", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := models.PersonStatsResponse{
+		GroupBy:   query.GroupBy,
+		AgeBucket: query.AgeBucket,
+		Groups:    make([]models.PersonStatsGroup, len(stats)),
+	}
+	if response.GroupBy == nil {
+		response.GroupBy = []string{}
+	}
+	for i, s := range stats {
+		response.Total += s.Count
+		response.Groups[i] = newStatsGroup(s, query, aggregates)
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+func newStatsGroup(s models.PersonStats, query models.PersonStatsQuery, aggregates []string) models.PersonStatsGroup {
+	group := models.PersonStatsGroup{Group: map[string]any{}}
+	for _, field := range query.GroupBy {
+		switch field {
+		case models.GroupByGender:
+			group.Group[field] = s.Gender
+		case models.GroupByNationality:
+			group.Group[field] = s.Nationality
+		case models.GroupByAge:
+			group.Group[field] = nil
+			if s.AgeFrom != nil {
+				group.Group[field] = fmt.Sprintf("%d-%d", *s.AgeFrom, *s.AgeFrom+query.AgeBucket-1)
+			}
+		}
+	}
+	for _, agg := range aggregates {
+		switch agg {
+		case aggCount:
+			group.Count = &s.Count
+		case aggAvgAge:
+			group.AvgAge = s.AvgAge
+		case aggMinAge:
+			group.MinAge = s.MinAge
+		case aggMaxAge:
+			group.MaxAge = s.MaxAge
+		}
+	}
+	return group
+}
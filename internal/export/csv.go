@@ -0,0 +1,55 @@
+package export
+
+import (
+	"encoding/csv"
+	"future_today/models"
+	"io"
+)
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.name
+	}
+	return c.w.Write(names)
+}
+
+func (c *csvWriter) Write(person *models.Person) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		record[i] = formatValue(col.value(person))
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Fail(error) error {
+	return c.Flush()
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.Flush()
+}
@@ -0,0 +1,98 @@
+package export
+
+import (
+	"fmt"
+	"future_today/models"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Форматы выгрузки
+const (
+	CSV    = "csv"
+	NDJSON = "ndjson"
+	XLSX   = "xlsx"
+)
+
+// Writer пишет персон в выгрузку по одной. Close дописывает хвост
+// формата, без него файл неполный
+type Writer interface {
+	Write(person *models.Person) error
+	// Flush отправляет буферизованные строки в io.Writer
+	Flush() error
+	Close() error
+	// Fail завершает оборванную выгрузку записью об ошибке, если формат
+	// это позволяет: NDJSON - объект {"error": ...}, XLSX - последняя
+	// строка листа. В CSV запись не добавляется, чтобы не смешать ее с данными
+	Fail(cause error) error
+}
+
+// NewWriter пишет выгрузку в формате format в w
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w), nil
+	case NDJSON:
+		return newNDJSONWriter(w), nil
+	case XLSX:
+		return newXLSXWriter(w)
+	}
+	return nil, fmt.Errorf("unknown export format %q, allowed: csv, ndjson, xlsx", format)
+}
+
+// ContentType MIME-тип формата
+func ContentType(format string) string {
+	switch format {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case NDJSON:
+		return "application/x-ndjson"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+// column колонка выгрузки. value возвращает string, int, uint, float64 или
+// nil для пустого значения
+type column struct {
+	name  string
+	value func(p *models.Person) any
+}
+
+var columns = []column{
+	{"id", func(p *models.Person) any { return p.ID }},
+	{"name", func(p *models.Person) any { return p.Name }},
+	{"surname", func(p *models.Person) any { return p.Surname }},
+	{"patronymic", func(p *models.Person) any { return p.Patronymic }},
+	{"age", func(p *models.Person) any { return p.Age }},
+	{"gender", func(p *models.Person) any { return p.Gender }},
+	{"gender_probability", func(p *models.Person) any { return p.GenderProbability }},
+	{"nationality", func(p *models.Person) any { return p.Nationality }},
+	{"nationality_probability", func(p *models.Person) any { return p.NationalityProbability }},
+	{"enrichment_pending", func(p *models.Person) any { return p.EnrichmentPending }},
+	{"enriched_at", func(p *models.Person) any { return formatTime(p.EnrichedAt) }},
+	{"created_at", func(p *models.Person) any { return formatTime(&p.CreatedAt) }},
+	{"updated_at", func(p *models.Person) any { return formatTime(&p.UpdatedAt) }},
+}
+
+func formatTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// formatValue значение колонки текстом для CSV и XLSX
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,232 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"future_today/models"
+	"io"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func testPersons() []*models.Person {
+	enriched := time.Date(2024, 6, 1, 12, 30, 0, 0, time.FixedZone("MSK", 3*3600))
+	created := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	anna := &models.Person{Name: "Анна", Surname: "Иванова", Patronymic: "Сергеевна", Age: 34, Gender: "female",
+		GenderProbability: 0.98, Nationality: "RU", NationalityProbability: 0.6, EnrichedAt: &enriched}
+	anna.ID, anna.CreatedAt, anna.UpdatedAt = 1, created, created
+	// разделители, кавычки и недопустимые в XML символы в значениях
+	boris := &models.Person{Name: "Boris, \"Bob\"", Surname: "Pet\x01rov\nJr", EnrichmentPending: true}
+	boris.ID, boris.CreatedAt, boris.UpdatedAt = 2, created, created
+	return []*models.Person{anna, boris}
+}
+
+// wantRecords ожидаемые строки выгрузки текстом, с заголовком
+var wantRecords = [][]string{
+	{"id", "name", "surname", "patronymic", "age", "gender", "gender_probability", "nationality", "nationality_probability",
+		"enrichment_pending", "enriched_at", "created_at", "updated_at"},
+	{"1", "Анна", "Иванова", "Сергеевна", "34", "female", "0.98", "RU", "0.6",
+		"false", "2024-06-01T09:30:00Z", "2024-05-01T08:00:00Z", "2024-05-01T08:00:00Z"},
+	{"2", "Boris, \"Bob\"", "Pet\x01rov\nJr", "", "0", "", "0", "", "0",
+		"true", "", "2024-05-01T08:00:00Z", "2024-05-01T08:00:00Z"},
+}
+
+func write(t *testing.T, format string, persons []*models.Person, cause error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	for _, p := range persons {
+		if err := w.Write(p); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if cause != nil {
+		err = w.Fail(cause)
+	} else {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func readCSV(t *testing.T, data []byte) [][]string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	return records
+}
+
+// readNDJSON объекты выгрузки строками в порядке колонок, ключи проверяются
+func readNDJSON(t *testing.T, data []byte) [][]string {
+	t.Helper()
+	records := [][]string{wantRecords[0]}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var obj map[string]any
+		if err := dec.Decode(&obj); errors.Is(err, io.EOF) {
+			return records
+		} else if err != nil {
+			t.Fatalf("read ndjson: %v", err)
+		}
+		if msg, ok := obj["error"]; ok && len(obj) == 1 {
+			records = append(records, []string{fmt.Sprint(msg)})
+			continue
+		}
+		if len(obj) != len(columns) {
+			t.Fatalf("object has %d keys, want %d", len(obj), len(columns))
+		}
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			switch v := obj[col.name].(type) {
+			case nil:
+			case float64:
+				record[i] = formatValue(v)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+// readXLSX значения ячеек листа, пропущенные ячейки - пустые строки
+func readXLSX(t *testing.T, data []byte) [][]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("read xlsx: %v", err)
+	}
+	f, err := zr.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatalf("open sheet: %v", err)
+	}
+	defer f.Close()
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.NewDecoder(f).Decode(&sheet); err != nil {
+		t.Fatalf("decode sheet: %v", err)
+	}
+	var records [][]string
+	for r, row := range sheet.Rows {
+		var record []string
+		for _, c := range row.Cells {
+			col := 0
+			for col < len(columns) && cellName(col)+fmt.Sprint(r+1) != c.Ref {
+				col++
+			}
+			for len(record) < col {
+				record = append(record, "")
+			}
+			switch c.Type {
+			case "inlineStr":
+				record = append(record, c.Inline)
+			case "b":
+				record = append(record, map[string]string{"0": "false", "1": "true"}[c.Value])
+			default:
+				record = append(record, c.Value)
+			}
+		}
+		if len(record) > 1 {
+			for len(record) < len(columns) {
+				record = append(record, "")
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	// XLSX 1.0 не хранит управляющие символы, они вырезаются
+	xlsxRecords := make([][]string, len(wantRecords))
+	for i, r := range wantRecords {
+		xlsxRecords[i] = slices.Clone(r)
+	}
+	xlsxRecords[2][2] = "Petrov\nJr"
+
+	tests := []struct {
+		format string
+		read   func(*testing.T, []byte) [][]string
+		want   [][]string
+	}{
+		{CSV, readCSV, wantRecords},
+		{NDJSON, readNDJSON, wantRecords},
+		{XLSX, readXLSX, xlsxRecords},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got := tt.read(t, write(t, tt.format, testPersons(), nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExportFail оборванная выгрузка: данные до обрыва читаются, NDJSON
+// и XLSX заканчиваются записью об ошибке, CSV - нет
+func TestExportFail(t *testing.T) {
+	cause := errors.New("database is gone")
+	tests := []struct {
+		format string
+		read   func(*testing.T, []byte) [][]string
+		last   []string
+	}{
+		{CSV, readCSV, wantRecords[1]},
+		{NDJSON, readNDJSON, []string{cause.Error()}},
+		{XLSX, readXLSX, []string{"error: " + cause.Error()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got := tt.read(t, write(t, tt.format, testPersons()[:1], cause))
+			if !reflect.DeepEqual(got[:2], wantRecords[:2]) {
+				t.Errorf("rows before the error = %q", got[:2])
+			}
+			if last := got[len(got)-1]; !reflect.DeepEqual(last, tt.last) {
+				t.Errorf("last row = %q, want %q", last, tt.last)
+			}
+		})
+	}
+}
+
+func TestExportEmpty(t *testing.T) {
+	if got := readCSV(t, write(t, CSV, nil, nil)); !reflect.DeepEqual(got, wantRecords[:1]) {
+		t.Errorf("csv = %q, want only the header", got)
+	}
+	if got := write(t, NDJSON, nil, nil); len(got) != 0 {
+		t.Errorf("ndjson = %q, want empty", got)
+	}
+	if got := readXLSX(t, write(t, XLSX, nil, nil)); !reflect.DeepEqual(got, wantRecords[:1]) {
+		t.Errorf("xlsx = %q, want only the header", got)
+	}
+}
+
+func TestCellName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := cellName(i); got != want {
+			t.Errorf("cellName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
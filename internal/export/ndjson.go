@@ -0,0 +1,61 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"future_today/models"
+	"io"
+)
+
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buf := bufio.NewWriter(w)
+	return &ndjsonWriter{w: buf, enc: json.NewEncoder(buf)}
+}
+
+// Write одна персона - один JSON-объект с колонками выгрузки в строке
+func (n *ndjsonWriter) Write(person *models.Person) error {
+	row := make(orderedRow, len(columns))
+	for i, col := range columns {
+		row[i] = col.value(person)
+	}
+	return n.enc.Encode(row)
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return n.w.Flush()
+}
+
+func (n *ndjsonWriter) Fail(cause error) error {
+	if err := n.enc.Encode(map[string]string{"error": cause.Error()}); err != nil {
+		return err
+	}
+	return n.w.Flush()
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
+
+// orderedRow значения колонок, кодируется объектом с ключами в порядке columns
+type orderedRow []any
+
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	buf := []byte{'{'}
+	for i, col := range columns {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		key, _ := json.Marshal(col.name)
+		value, err := json.Marshal(r[i])
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(append(buf, key...), ':'), value...)
+	}
+	return append(buf, '}'), nil
+}
@@ -0,0 +1,52 @@
+package filterexpr
+
+// Expr узел дерева фильтра: *Logical, *Not или *Compare
+type Expr interface {
+	// Pos позиция узла в исходной строке, с 1
+	Pos() int
+}
+
+// Op оператор сравнения
+type Op string
+
+const (
+	Eq       Op = "eq"
+	Ne       Op = "ne"
+	Gt       Op = "gt"
+	Ge       Op = "ge"
+	Lt       Op = "lt"
+	Le       Op = "le"
+	Contains Op = "contains"
+	In       Op = "in"
+)
+
+var ops = []Op{Eq, Ne, Gt, Ge, Lt, Le, Contains, In}
+
+// Logical and/or двух выражений
+type Logical struct {
+	And         bool
+	Left, Right Expr
+	pos         int
+}
+
+func (e *Logical) Pos() int { return e.pos }
+
+// Not отрицание выражения
+type Not struct {
+	X   Expr
+	pos int
+}
+
+func (e *Not) Pos() int { return e.pos }
+
+// Compare сравнение поля с литералами. Для In значений несколько,
+// для остальных операторов - одно. Значения уже приведены к типу поля:
+// string, int64, float64, bool или time.Time
+type Compare struct {
+	Field  Field
+	Op     Op
+	Values []any
+	pos    int
+}
+
+func (e *Compare) Pos() int { return e.pos }
@@ -0,0 +1,127 @@
+package filterexpr
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Match вычисляет фильтр для record (структура или указатель на нее, из
+// которой построены поля) так же, как база: сравнение с NULL дает
+// неизвестность, а неизвестный результат - не совпадение
+func Match(e Expr, record any) bool {
+	return eval(e, reflect.Indirect(reflect.ValueOf(record))) == yes
+}
+
+// truth трехзначная логика SQL
+type truth int
+
+const (
+	no truth = iota
+	yes
+	unknown
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return yes
+	}
+	return no
+}
+
+func eval(e Expr, record reflect.Value) truth {
+	switch e := e.(type) {
+	case *Logical:
+		l, r := eval(e.Left, record), eval(e.Right, record)
+		if e.And {
+			switch {
+			case l == no || r == no:
+				return no
+			case l == yes && r == yes:
+				return yes
+			}
+			return unknown
+		}
+		switch {
+		case l == yes || r == yes:
+			return yes
+		case l == no && r == no:
+			return no
+		}
+		return unknown
+	case *Not:
+		switch x := eval(e.X, record); x {
+		case yes:
+			return no
+		case no:
+			return yes
+		}
+		return unknown
+	case *Compare:
+		return compare(e, record)
+	}
+	return unknown
+}
+
+func compare(e *Compare, record reflect.Value) truth {
+	v := record.FieldByIndex(e.Field.index)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return unknown
+		}
+		v = v.Elem()
+	}
+	switch e.Op {
+	case In:
+		return truthOf(slices.ContainsFunc(e.Values, func(want any) bool { return compareValue(v, want) == 0 }))
+	case Contains:
+		return truthOf(strings.Contains(strings.ToLower(v.String()), strings.ToLower(e.Values[0].(string))))
+	}
+	c := compareValue(v, e.Values[0])
+	switch e.Op {
+	case Eq:
+		return truthOf(c == 0)
+	case Ne:
+		return truthOf(c != 0)
+	case Gt:
+		return truthOf(c > 0)
+	case Ge:
+		return truthOf(c >= 0)
+	case Lt:
+		return truthOf(c < 0)
+	case Le:
+		return truthOf(c <= 0)
+	}
+	return unknown
+}
+
+// compareValue сравнивает значение поля с литералом того же Kind
+func compareValue(v reflect.Value, want any) int {
+	switch want := want.(type) {
+	case string:
+		return cmp.Compare(v.String(), want)
+	case int64:
+		if v.CanUint() {
+			if want < 0 {
+				return 1
+			}
+			return cmp.Compare(v.Uint(), uint64(want))
+		}
+		return cmp.Compare(v.Int(), want)
+	case float64:
+		return cmp.Compare(v.Float(), want)
+	case bool:
+		if v.Bool() == want {
+			return 0
+		}
+		if want {
+			return -1
+		}
+		return 1
+	case time.Time:
+		return v.Interface().(time.Time).Compare(want)
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package filterexpr
+
+import (
+	"reflect"
+	"slices"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// Kind тип значения поля для проверки литералов и операторов
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Float
+	Bool
+	Time
+)
+
+func (k Kind) String() string {
+	return [...]string{"string", "int", "float", "bool", "time"}[k]
+}
+
+// Field поле модели, доступное в фильтре. Name - имя колонки
+type Field struct {
+	Name  string
+	Kind  Kind
+	index []int
+}
+
+// Fields поля модели по имени колонки
+type Fields map[string]Field
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// FieldsOf поля структуры model, включая встроенные (gorm.Model), с именами
+// колонок как у gorm. Поля, которые не приводятся к Kind, пропускаются
+func FieldsOf(model any) Fields {
+	fields := Fields{}
+	collectFields(fields, reflect.TypeOf(model), nil)
+	return fields
+}
+
+func collectFields(fields Fields, t reflect.Type, index []int) {
+	naming := schema.NamingStrategy{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		idx := append(slices.Clone(index), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			collectFields(fields, f.Type, idx)
+			continue
+		}
+		if kind, ok := kindOf(f.Type); ok {
+			name := naming.ColumnName("", f.Name)
+			fields[name] = Field{Name: name, Kind: kind, index: idx}
+		}
+	}
+}
+
+func kindOf(t reflect.Type) (Kind, bool) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return Time, true
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return String, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Int, true
+	case reflect.Float32, reflect.Float64:
+		return Float, true
+	case reflect.Bool:
+		return Bool, true
+	}
+	return 0, false
+}
+
+// Only подмножество полей с именами names. Неизвестное имя - ошибка
+// в коде, а не во входных данных, поэтому паника
+func (fs Fields) Only(names ...string) Fields {
+	out := make(Fields, len(names))
+	for _, name := range names {
+		f, ok := fs[name]
+		if !ok {
+			panic("filterexpr: unknown field " + name)
+		}
+		out[name] = f
+	}
+	return out
+}
+
+// Names имена полей по алфавиту
+func (fs Fields) Names() []string {
+	names := make([]string, 0, len(fs))
+	for name := range fs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
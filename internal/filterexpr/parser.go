@@ -0,0 +1,322 @@
+package filterexpr
+
+import (
+	"fmt"
+	"future_today/internal/cerrors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Error ошибка разбора фильтра с позицией (в символах, с 1)
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return cerrors.ErrInvalidFilter
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of filter"
+	}
+	return strconv.Quote(t.text)
+}
+
+// lex разбивает строку на токены. Строки в двойных кавычках, внутри
+// допускаются \" и \\
+func lex(input string) ([]token, error) {
+	runes := []rune(input)
+	var tokens []token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", pos})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokComma, ",", pos})
+			i++
+		case r == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, &Error{Pos: pos, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, token{tokString, b.String(), pos})
+			i++
+		case r == '-' || r == '.' || unicode.IsDigit(r):
+			start := i
+			for i++; i < len(runes) && (runes[i] == '.' || unicode.IsDigit(runes[i])); i++ {
+			}
+			tokens = append(tokens, token{tokNumber, string(runes[start:i]), pos})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i++; i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_'); i++ {
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[start:i]), pos})
+		default:
+			return nil, &Error{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(runes) + 1}), nil
+}
+
+// MaxDepth наибольшая вложенность not и скобок в фильтре
+const MaxDepth = 32
+
+type parser struct {
+	tokens []token
+	i      int
+	fields Fields
+	depth  int
+}
+
+// Parse разбирает фильтр и проверяет поля, операторы и типы литералов
+// по fields. Пустая строка - фильтра нет, nil без ошибки.
+//
+//	expr    = and { "or" and }
+//	and     = unary { "and" unary }
+//	unary   = "not" unary | "(" expr ")" | compare
+//	compare = field op value | field "in" "(" value { "," value } ")"
+//	value   = "string" | number | true | false
+func Parse(input string, fields Fields) (Expr, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, fields: fields}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s, expected \"and\" or \"or\"", t)}
+	}
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokIdent && strings.EqualFold(t.text, word) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		pos := p.peek().pos
+		if !p.keyword("or") {
+			return left, nil
+		}
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Left: left, Right: right, pos: pos}
+	}
+}
+
+func (p *parser) and() (Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		pos := p.peek().pos
+		if !p.keyword("and") {
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{And: true, Left: left, Right: right, pos: pos}
+	}
+}
+
+func (p *parser) unary() (Expr, error) {
+	pos := p.peek().pos
+	if t := p.peek(); t.kind == tokLParen || t.kind == tokIdent && strings.EqualFold(t.text, "not") {
+		if p.depth == MaxDepth {
+			return nil, &Error{Pos: pos, Msg: fmt.Sprintf("filter is nested deeper than %d levels", MaxDepth)}
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+	}
+	if p.keyword("not") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x, pos: pos}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected \")\", got %s", t)}
+		}
+		return x, nil
+	}
+	return p.compare()
+}
+
+func (p *parser) compare() (Expr, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected field name, got %s", t)}
+	}
+	field, ok := p.fields[t.text]
+	if !ok {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unknown field %q, allowed: %s", t.text, strings.Join(p.fields.Names(), ", "))}
+	}
+
+	opTok := p.next()
+	op := Op(strings.ToLower(opTok.text))
+	if opTok.kind != tokIdent || !slices.Contains(ops, op) {
+		return nil, &Error{Pos: opTok.pos, Msg: fmt.Sprintf("expected operator (%s), got %s", opsList(), opTok)}
+	}
+	switch {
+	case op == Contains && field.Kind != String:
+		return nil, &Error{Pos: opTok.pos, Msg: fmt.Sprintf("operator contains needs a string field, %s is %s", field.Name, field.Kind)}
+	case field.Kind == Bool && op != Eq && op != Ne && op != In:
+		return nil, &Error{Pos: opTok.pos, Msg: fmt.Sprintf("operator %s isn't supported for bool field %s", op, field.Name)}
+	}
+
+	cmp := &Compare{Field: field, Op: op, pos: t.pos}
+	if op != In {
+		v, err := p.value(field)
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = []any{v}
+		return cmp, nil
+	}
+	if t := p.next(); t.kind != tokLParen {
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected \"(\" after in, got %s", t)}
+	}
+	for {
+		v, err := p.value(field)
+		if err != nil {
+			return nil, err
+		}
+		cmp.Values = append(cmp.Values, v)
+		t := p.next()
+		if t.kind == tokRParen {
+			return cmp, nil
+		}
+		if t.kind != tokComma {
+			return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("expected \",\" or \")\", got %s", t)}
+		}
+	}
+}
+
+// value литерал, приведенный к типу поля
+func (p *parser) value(field Field) (any, error) {
+	t := p.next()
+	bad := &Error{Pos: t.pos, Msg: fmt.Sprintf("expected %s value for %s, got %s", field.Kind, field.Name, t)}
+	switch field.Kind {
+	case String:
+		if t.kind == tokString {
+			return t.text, nil
+		}
+	case Int:
+		if t.kind == tokNumber {
+			if v, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+				return v, nil
+			}
+		}
+	case Float:
+		if t.kind == tokNumber {
+			if v, err := strconv.ParseFloat(t.text, 64); err == nil && !math.IsInf(v, 0) {
+				return v, nil
+			}
+		}
+	case Bool:
+		if t.kind == tokIdent && (t.text == "true" || t.text == "false") {
+			return t.text == "true", nil
+		}
+	case Time:
+		if t.kind == tokString {
+			for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+				if v, err := time.Parse(layout, t.text); err == nil {
+					return v, nil
+				}
+			}
+			bad.Msg += ", use RFC 3339 or YYYY-MM-DD"
+		}
+	}
+	return nil, bad
+}
+
+func opsList() string {
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = string(op)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,193 @@
+package filterexpr
+
+import (
+	"errors"
+	"fmt"
+	"future_today/internal/cerrors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRecord struct {
+	Name       string
+	Age        int
+	Score      float64
+	Active     bool
+	EnrichedAt *time.Time
+}
+
+var testFields = FieldsOf(testRecord{})
+
+// format выражение со всеми скобками, чтобы было видно, как оно разобрано
+func format(e Expr) string {
+	switch e := e.(type) {
+	case *Logical:
+		op := "or"
+		if e.And {
+			op = "and"
+		}
+		return "(" + format(e.Left) + " " + op + " " + format(e.Right) + ")"
+	case *Not:
+		return "(not " + format(e.X) + ")"
+	case *Compare:
+		values := make([]string, len(e.Values))
+		for i, v := range e.Values {
+			values[i] = fmt.Sprintf("%#v", v)
+		}
+		return e.Field.Name + " " + string(e.Op) + " " + strings.Join(values, ",")
+	}
+	return "?"
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`age gt 1 or age lt 5 and name eq "a"`, `(age gt 1 or (age lt 5 and name eq "a"))`},
+		{`age gt 1 and age lt 5 or name eq "a"`, `((age gt 1 and age lt 5) or name eq "a")`},
+		{`(age gt 1 or age lt 5) and name eq "a"`, `((age gt 1 or age lt 5) and name eq "a")`},
+		{`age eq 1 or age eq 2 or age eq 3`, `((age eq 1 or age eq 2) or age eq 3)`},
+		{`not age eq 1 and name eq "a"`, `((not age eq 1) and name eq "a")`},
+		{`not (age eq 1 and name eq "a")`, `(not (age eq 1 and name eq "a"))`},
+		{`not not active eq true`, `(not (not active eq true))`},
+		{`age GT 1 AND name Contains "x"`, `(age gt 1 and name contains "x")`},
+		{`name in ("a", "b \"c\"")`, `name in "a","b \"c\""`},
+		{`age in (1) or score in (0.5, -1)`, `(age in 1 or score in 0.5,-1)`},
+		{`not age in (1, 2)`, `(not age in 1,2)`},
+		{`active ne false`, `active ne false`},
+		{`enriched_at ge "2024-05-01"`, `enriched_at ge time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input, testFields)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := format(e); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	e, err := Parse("  ", testFields)
+	if e != nil || err != nil {
+		t.Errorf("Parse(blank) = %v, %v, want nil, nil", e, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{`nam eq "a"`, 1, `unknown field "nam"`},
+		{`age foo 1`, 5, `expected operator`},
+		{`age eq`, 7, `expected int value for age, got end of filter`},
+		{`age eq "1"`, 8, `expected int value`},
+		{`name eq "abc`, 9, `unterminated string`},
+		{`age eq 1 # 2`, 10, `unexpected character '#'`},
+		{`age eq 1 and (name eq "a"`, 26, `expected ")", got end of filter`},
+		{`name eq "a" name eq "b"`, 13, `expected "and" or "or"`},
+		{`age contains "a"`, 5, `operator contains needs a string field`},
+		{`active gt true`, 8, `operator gt isn't supported for bool field active`},
+		{`age in 1`, 8, `expected "(" after in`},
+		{`age in (1 2)`, 11, `expected "," or ")"`},
+		{`age in ()`, 9, `expected int value`},
+		{`not`, 4, `expected field name`},
+		{`enriched_at gt "yesterday"`, 16, `use RFC 3339 or YYYY-MM-DD`},
+		// позиции в символах, а не в байтах
+		{`name eq "ёж" and x eq 1`, 18, `unknown field "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input, testFields)
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("Parse error = %v, want *Error", err)
+			}
+			if !errors.Is(err, cerrors.ErrInvalidFilter) {
+				t.Errorf("error %v doesn't wrap ErrInvalidFilter", err)
+			}
+			if perr.Pos != tt.pos {
+				t.Errorf("position %d, want %d (%s)", perr.Pos, tt.pos, perr.Msg)
+			}
+			if !strings.Contains(perr.Msg, tt.msg) {
+				t.Errorf("message %q doesn't contain %q", perr.Msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	enriched := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	anna := testRecord{Name: "Анна", Age: 30, Score: 0.5, Active: true, EnrichedAt: &enriched}
+	pending := testRecord{Name: "Boris", Age: 40}
+
+	tests := []struct {
+		input         string
+		anna, pending bool
+	}{
+		{`age ge 30 and age lt 40`, true, false},
+		{`name contains "АН"`, true, false},
+		{`name in ("Boris", "Vera")`, false, true},
+		{`not name in ("Boris", "Vera")`, true, false},
+		{`active eq false`, false, true},
+		{`score gt 0.25`, true, false},
+		{`enriched_at gt "2024-01-01"`, true, false},
+		// сравнение с NULL неизвестно: ни условие, ни его отрицание не выполняются
+		{`not enriched_at gt "2024-01-01"`, false, false},
+		{`enriched_at lt "2024-01-01" or age eq 40`, false, true},
+		{`not (enriched_at lt "2024-01-01" and age eq 40)`, true, false},
+		{`not (enriched_at lt "2024-01-01" or age eq 30)`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			e, err := Parse(tt.input, testFields)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := Match(e, anna); got != tt.anna {
+				t.Errorf("Match(anna) = %v, want %v", got, tt.anna)
+			}
+			if got := Match(e, &pending); got != tt.pending {
+				t.Errorf("Match(pending) = %v, want %v", got, tt.pending)
+			}
+		})
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"parens at limit", strings.Repeat("(", MaxDepth) + "age eq 1" + strings.Repeat(")", MaxDepth), true},
+		{"parens over limit", strings.Repeat("(", MaxDepth+1) + "age eq 1" + strings.Repeat(")", MaxDepth+1), false},
+		{"not at limit", strings.Repeat("not ", MaxDepth) + "age eq 1", true},
+		{"not over limit", strings.Repeat("not ", MaxDepth+1) + "age eq 1", false},
+		{"mixed over limit", strings.Repeat("not (", MaxDepth) + "age eq 1" + strings.Repeat(")", MaxDepth), false},
+		// соседние скобки не складываются
+		{"siblings", strings.Repeat("(age eq 1) and ", 3*MaxDepth) + "age eq 2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input, testFields)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				return
+			}
+			var perr *Error
+			if !errors.As(err, &perr) || !strings.Contains(perr.Msg, "nested deeper") {
+				t.Errorf("Parse error = %v, want nesting *Error", err)
+			}
+		})
+	}
+}
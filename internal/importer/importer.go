@@ -0,0 +1,173 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"future_today/internal/cerrors"
+	"future_today/models"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Форматы файла импорта
+const (
+	CSV    = "csv"
+	NDJSON = "ndjson"
+)
+
+// maxLine предел длины строки NDJSON
+const maxLine = 1 << 20
+
+// Row строка файла. Err - строка не разобрана или не прошла проверку
+// CreatePersonRequest, остальные строки это не останавливает
+type Row struct {
+	Line    int
+	Request models.CreatePersonRequest
+	Err     error
+}
+
+// Reader читает файл по строке. Next возвращает io.EOF в конце файла,
+// другая ошибка - файл дальше читать нельзя
+type Reader interface {
+	Next() (*Row, error)
+}
+
+// DetectFormat формат из явного значения или по расширению файла
+func DetectFormat(format, filename string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".csv":
+			format = CSV
+		case ".ndjson", ".jsonl":
+			format = NDJSON
+		}
+	}
+	switch format {
+	case CSV, NDJSON:
+		return format, nil
+	case "":
+		return "", fmt.Errorf("%w: can't detect format of %q, pass format=csv|ndjson", cerrors.ErrInvalidImport, filename)
+	}
+	return "", fmt.Errorf("%w: unknown format %q, allowed: csv, ndjson", cerrors.ErrInvalidImport, format)
+}
+
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r)
+	case NDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxLine)
+		return &ndjsonReader{scanner: scanner}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown format %q", cerrors.ErrInvalidImport, format)
+}
+
+// validate проверка строки теми же правилами, что и POST /persons
+func validate(row *Row) *Row {
+	if row.Err == nil {
+		row.Request.Normalize()
+		row.Err = binding.Validator.ValidateStruct(&row.Request)
+	}
+	return row
+}
+
+// csvColumns колонки CSV, name и surname обязательны
+var csvColumns = []string{"name", "surname", "patronymic", "country_hint"}
+
+type csvReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: empty file", cerrors.ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad header: %v", cerrors.ErrInvalidImport, err)
+	}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(csvColumns, name) {
+			return nil, fmt.Errorf("%w: unknown column %q, allowed: %s", cerrors.ErrInvalidImport, name, strings.Join(csvColumns, ", "))
+		}
+		if slices.Contains(header[:i], name) {
+			return nil, fmt.Errorf("%w: column %q repeated", cerrors.ErrInvalidImport, name)
+		}
+		header[i] = name
+	}
+	for _, required := range csvColumns[:2] {
+		if !slices.Contains(header, required) {
+			return nil, fmt.Errorf("%w: missing column %q", cerrors.ErrInvalidImport, required)
+		}
+	}
+	return &csvReader{r: cr, header: header}, nil
+}
+
+func (c *csvReader) Next() (*Row, error) {
+	record, err := c.r.Read()
+	var perr *csv.ParseError
+	switch {
+	case errors.As(err, &perr):
+		// битая строка не мешает читать следующие
+		return &Row{Line: perr.StartLine, Err: perr.Err}, nil
+	case err != nil:
+		return nil, err
+	}
+	line, _ := c.r.FieldPos(0)
+	row := &Row{Line: line}
+	if len(record) != len(c.header) {
+		row.Err = fmt.Errorf("expected %d fields, got %d", len(c.header), len(record))
+		return row, nil
+	}
+	for i, value := range record {
+		switch c.header[i] {
+		case "name":
+			row.Request.Name = value
+		case "surname":
+			row.Request.Surname = value
+		case "patronymic":
+			row.Request.Patronymic = value
+		case "country_hint":
+			row.Request.CountryHint = value
+		}
+	}
+	return validate(row), nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonReader) Next() (*Row, error) {
+	for n.scanner.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		row := &Row{Line: n.line}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		row.Err = dec.Decode(&row.Request)
+		return validate(row), nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %w", n.line+1, err)
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"errors"
+	"future_today/internal/cerrors"
+	"future_today/models"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// readAll строки файла до io.EOF или ошибки чтения. Ошибки строк
+// заменены на invalid, чтобы строки можно было сравнить
+func readAll(t *testing.T, format string, input io.Reader) ([]Row, error) {
+	t.Helper()
+	r, err := NewReader(format, input)
+	if err != nil {
+		t.Fatalf("NewReader: %v", err)
+	}
+	var rows []Row
+	for {
+		row, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		if row.Err != nil {
+			row.Request, row.Err = models.CreatePersonRequest{}, invalid
+		}
+		rows = append(rows, *row)
+	}
+}
+
+var invalid = errors.New("invalid")
+
+func TestReadRows(t *testing.T) {
+	anna := models.CreatePersonRequest{Name: "Анна", Surname: "Иванова", Patronymic: "Сергеевна", CountryHint: "RU"}
+	boris := models.CreatePersonRequest{Name: "Boris", Surname: "Petrov"}
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   []Row
+	}{
+		{"csv", CSV,
+			"\ufeffName, surname,patronymic,country_hint\nАнна,Иванова,Сергеевна,ru\nBoris,Petrov,,\n",
+			[]Row{{Line: 2, Request: anna}, {Line: 3, Request: boris}}},
+		{"csv columns in any order", CSV,
+			"surname,name\nPetrov,Boris\n",
+			[]Row{{Line: 2, Request: boris}}},
+		{"csv bad rows", CSV,
+			"name,surname,country_hint\nBoris,Petrov\n,Petrov,\nBoris,Petrov,XX\n\"Bo\"ris,Petrov,\nBoris,Petrov,\n",
+			[]Row{
+				{Line: 2, Err: invalid},
+				{Line: 3, Err: invalid},
+				{Line: 4, Err: invalid},
+				{Line: 5, Err: invalid},
+				{Line: 6, Request: boris},
+			}},
+		{"ndjson", NDJSON,
+			`{"name":"Анна","surname":"Иванова","patronymic":"Сергеевна","country_hint":" ru "}` + "\n\n" +
+				`{"name":"Boris","surname":"Petrov"}` + "\r\n",
+			[]Row{{Line: 1, Request: anna}, {Line: 3, Request: boris}}},
+		{"ndjson bad rows", NDJSON,
+			`{"name":"Boris"}` + "\n" + `{"name":"Boris","surname":"Petrov","age":30}` + "\n" + `not json` + "\n" + `{"name":"Boris","surname":"Petrov"}`,
+			[]Row{{Line: 1, Err: invalid}, {Line: 2, Err: invalid}, {Line: 3, Err: invalid}, {Line: 4, Request: boris}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAll(t, tt.format, strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadBrokenFile строки до обрыва файла отдаются, затем ошибка чтения
+func TestReadBrokenFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  io.Reader
+	}{
+		{"ndjson line too long", NDJSON, strings.NewReader(`{"name":"Boris","surname":"Petrov"}` + "\n" + `{"name":"Vera","surname":"Orlova"}` + "\n" +
+			strings.Repeat("x", maxLine+1) + "\n" + `{"name":"Gleb","surname":"Orlov"}`)},
+		{"csv connection reset", CSV, resetReader("name,surname\nBoris,Petrov\nVera,Orlova\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAll(t, tt.format, tt.input)
+			if err == nil {
+				t.Fatal("file read to the end, want a read error")
+			}
+			var names []string
+			for _, row := range got {
+				names = append(names, row.Request.Name)
+			}
+			if want := []string{"Boris", "Vera"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("rows before the error = %v, want %v", names, want)
+			}
+		})
+	}
+}
+
+// resetReader поток, оборванный после data
+func resetReader(data string) io.Reader {
+	return io.MultiReader(strings.NewReader(data), iotest.ErrReader(errors.New("connection reset")))
+}
+
+func TestReaderHeaderErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"", "empty file"},
+		{"name,age\n", `unknown column "age"`},
+		{"name,surname,name\n", `column "name" repeated`},
+		{"name,patronymic\n", `missing column "surname"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			_, err := NewReader(CSV, strings.NewReader(tt.input))
+			if !errors.Is(err, cerrors.ErrInvalidImport) || !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("NewReader error = %v, want %q", err, tt.msg)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		format, filename, want string
+		ok                     bool
+	}{
+		{"", "persons.CSV", CSV, true},
+		{"", "persons.jsonl", NDJSON, true},
+		{"ndjson", "persons.csv", NDJSON, true},
+		{"", "persons.txt", "", false},
+		{"xml", "persons.xml", "", false},
+	}
+	for _, tt := range tests {
+		got, err := DetectFormat(tt.format, tt.filename)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("DetectFormat(%q, %q) = %q, %v", tt.format, tt.filename, got, err)
+		}
+		if err != nil && !errors.Is(err, cerrors.ErrInvalidImport) {
+			t.Errorf("error %v doesn't wrap ErrInvalidImport", err)
+		}
+	}
+}
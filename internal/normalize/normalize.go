@@ -0,0 +1,102 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+type Scheme string
+
+const (
+	// SchemeNone без транслитерации
+	SchemeNone Scheme = "none"
+	// SchemeGOST ГОСТ 7.79-2000, система Б
+	SchemeGOST Scheme = "gost"
+	// SchemeICAO ICAO Doc 9303, как в загранпаспортах РФ
+	SchemeICAO Scheme = "icao"
+)
+
+// Normalizer приводит имя к виду, в котором его понимают провайдеры
+// обогащения: обрезка и схлопывание пробелов, NFC, case folding
+// и транслитерация кириллицы в латиницу
+type Normalizer struct {
+	scheme Scheme
+	fold   cases.Caser
+}
+
+func New(scheme string) (*Normalizer, error) {
+	switch s := Scheme(strings.ToLower(scheme)); s {
+	case SchemeNone, SchemeGOST, SchemeICAO:
+		return &Normalizer{scheme: s, fold: cases.Fold()}, nil
+	default:
+		return nil, fmt.Errorf("unknown transliteration scheme %q", scheme)
+	}
+}
+
+func (n *Normalizer) Normalize(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	name = norm.NFC.String(name)
+	name = n.fold.String(name)
+	switch n.scheme {
+	case SchemeGOST:
+		// апострофы ГОСТ (y`, ``, `) провайдеры не знают, в запрос они не идут
+		return strings.ReplaceAll(transliterate(name, gost, gostC), "`", "")
+	case SchemeICAO:
+		return transliterate(name, icao, nil)
+	}
+	return name
+}
+
+// transliterate заменяет строчную кириллицу по таблице, special
+// обрабатывает буквы, зависящие от следующей
+func transliterate(s string, table map[rune]string, special func(next rune) string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if special != nil && r == 'ц' {
+			next := rune(0)
+			if i+1 < len(runes) {
+				next = runes[i+1]
+			}
+			b.WriteString(special(next))
+			continue
+		}
+		if lat, ok := table[r]; ok {
+			b.WriteString(lat)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// gostC в ГОСТ 7.79 "ц" перед е, и, ы, й пишется как c, иначе cz
+func gostC(next rune) string {
+	switch unicode.ToLower(next) {
+	case 'е', 'и', 'ы', 'й', 'і', 'є':
+		return "c"
+	}
+	return "cz"
+}
+
+var gost = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "j", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "x", 'ч': "ch", 'ш': "sh", 'щ': "shh", 'ъ': "``",
+	'ы': "y`", 'ь': "`", 'э': "e`", 'ю': "yu", 'я': "ya",
+	'і': "i`", 'ї': "yi", 'є': "ye", 'ґ': "g`",
+}
+
+var icao = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "ie",
+	'ы': "y", 'ь': "", 'э': "e", 'ю': "iu", 'я': "ia",
+	'і': "i", 'ї': "i", 'є': "ie", 'ґ': "g",
+}
@@ -0,0 +1,56 @@
+package normalize
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		scheme string
+		input  string
+		want   string
+	}{
+		{"none", "  Анна   МАРИЯ ", "анна мария"},
+		// И с отдельным знаком краткости собирается в Й (NFC)
+		{"none", "\u0418\u0306ОШКАР", "йошкар"},
+		{"none", "Straße", "strasse"},
+
+		{"gost", "Щукин", "shhukin"},
+		{"gost", "Ёжиков", "yozhikov"},
+		{"gost", "Цыганов", "cyganov"},
+		{"gost", "Лицо", "liczo"},
+		{"gost", "Кузнец", "kuznecz"},
+		// апострофы ГОСТ для ъ, ь, э, ы не попадают в запрос
+		{"gost", "Подъездов", "podezdov"},
+		{"gost", "Эльвира", "elvira"},
+		{"gost", "Рыбаков", "rybakov"},
+		{"gost", "Ольга  Петровна ", "olga petrovna"},
+		{"GOST", "IVAN", "ivan"},
+
+		{"icao", "Щукин", "shchukin"},
+		{"icao", "Юлия", "iuliia"},
+		{"icao", "Хабибуллин", "khabibullin"},
+		{"icao", "Ёлкин", "elkin"},
+		{"icao", "Наталья", "natalia"},
+		{"icao", "Подъездов", "podieezdov"},
+		{"icao", "Цой Андрей", "tsoi andrei"},
+		{"icao", "Пётр O'Brien", "petr o'brien"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scheme+"/"+tt.input, func(t *testing.T) {
+			n, err := New(tt.scheme)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if got := n.Normalize(tt.input); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewUnknownScheme(t *testing.T) {
+	for _, scheme := range []string{"", "bgn", "iso9"} {
+		if _, err := New(scheme); err == nil {
+			t.Errorf("New(%q) without error", scheme)
+		}
+	}
+}
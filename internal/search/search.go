@@ -0,0 +1,149 @@
+package search
+
+import (
+	"future_today/internal/normalize"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Threshold порог похожести слова, как pg_trgm.word_similarity_threshold
+const Threshold = 0.6
+
+// latin транслитерация для поиска. Схема всегда ICAO, а не
+// ENRICH_TRANSLITERATION: people.search_name не должен зависеть от настроек
+var latin = func() *normalize.Normalizer {
+	n, err := normalize.New(string(normalize.SchemeICAO))
+	if err != nil {
+		panic(err)
+	}
+	return n
+}()
+
+// Latin части имени через пробел латиницей в нижнем регистре:
+// "Дмитрий" -> "dmitrii". Так хранится people.search_name
+func Latin(parts ...string) string {
+	return latin.Normalize(strings.Join(parts, " "))
+}
+
+// Words слова строки в нижнем регистре, разделители - все, кроме букв и цифр
+func Words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// trigrams триграммы слова как в pg_trgm: слово дополняется двумя
+// пробелами в начале и одним в конце
+func trigrams(word string) map[string]struct{} {
+	runes := []rune("  " + word + " ")
+	out := make(map[string]struct{}, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		out[string(runes[i:i+3])] = struct{}{}
+	}
+	return out
+}
+
+// WordSimilarity доля триграмм term, найденных в word. Опечатка в конце
+// слова ("ushakoff" и "ushakov") и префикс ("ush") дают высокую похожесть
+func WordSimilarity(term, word string) float64 {
+	if term == word {
+		return 1
+	}
+	t, w := trigrams(term), trigrams(word)
+	if len(t) == 0 {
+		return 0
+	}
+	common := 0
+	for tg := range t {
+		if _, ok := w[tg]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(t))
+}
+
+// similarity похожесть слова на слово запроса как есть или в
+// транслитерации, чтобы "dmitrii" находил "Дмитрий"
+func similarity(term, latinTerm, word, latinWord string) float64 {
+	return max(WordSimilarity(term, word), WordSimilarity(latinTerm, latinWord))
+}
+
+// Score релевантность текста запросу: средняя по словам запроса лучшая
+// похожесть на слово текста. 0 - текст не подходит
+func Score(query string, texts ...string) float64 {
+	terms := Words(query)
+	if len(terms) == 0 {
+		return 0
+	}
+	var words []string
+	for _, text := range texts {
+		words = append(words, Words(text)...)
+	}
+	latinWords := make([]string, len(words))
+	for i, word := range words {
+		latinWords[i] = Latin(word)
+	}
+	total := 0.0
+	for _, term := range terms {
+		best := 0.0
+		latinTerm := Latin(term)
+		for i, word := range words {
+			best = max(best, similarity(term, latinTerm, word, latinWords[i]))
+		}
+		total += best
+	}
+	score := total / float64(len(terms))
+	if score < Threshold {
+		return 0
+	}
+	return score
+}
+
+// Highlight оборачивает в <em> слова text, похожие на слова запроса,
+// остальной текст экранируется для HTML. Пустая строка, если совпадений нет
+func Highlight(query, text string) string {
+	terms := Words(query)
+	latinTerms := make([]string, len(terms))
+	for i, term := range terms {
+		latinTerms[i] = Latin(term)
+	}
+	var b strings.Builder
+	matched := false
+	word := []rune{}
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		w := string(word)
+		lower := strings.ToLower(w)
+		latinWord := Latin(lower)
+		hit := false
+		for i, term := range terms {
+			if similarity(term, latinTerms[i], lower, latinWord) >= Threshold {
+				hit = true
+				break
+			}
+		}
+		if hit {
+			matched = true
+			b.WriteString("<em>" + html.EscapeString(w) + "</em>")
+		} else {
+			b.WriteString(html.EscapeString(w))
+		}
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	flush()
+	if !matched {
+		return ""
+	}
+	return b.String()
+}
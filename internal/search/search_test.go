@@ -0,0 +1,59 @@
+package search
+
+import "testing"
+
+func TestLatin(t *testing.T) {
+	tests := []struct {
+		parts []string
+		want  string
+	}{
+		{[]string{"Дмитрий", "Ушаков", "Юрьевич"}, "dmitrii ushakov iurevich"},
+		{[]string{"Anna", "", "Иванова"}, "anna ivanova"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := Latin(tt.parts...); got != tt.want {
+			t.Errorf("Latin(%q) = %q, want %q", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		query string
+		texts []string
+		match bool
+	}{
+		{"Ушаков", []string{"Дмитрий", "Ушаков"}, true},
+		{"ushakoff", []string{"Дмитрий", "Ушаков"}, true},
+		{"dmitrii", []string{"Дмитрий", "Ушаков"}, true},
+		{"ush", []string{"Ushakov"}, true},
+		{"Петров", []string{"Дмитрий", "Ушаков"}, false},
+		{"dmitrii petrov", []string{"Дмитрий", "Ушаков"}, false},
+		{"  ", []string{"Дмитрий"}, false},
+	}
+	for _, tt := range tests {
+		if got := Score(tt.query, tt.texts...); (got > 0) != tt.match {
+			t.Errorf("Score(%q, %q) = %v, want match %v", tt.query, tt.texts, got, tt.match)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		query, text, want string
+	}{
+		{"ушаков", "Ушаков", "<em>Ушаков</em>"},
+		{"dmitrii", "Дмитрий-Сергей", "<em>Дмитрий</em>-Сергей"},
+		{"anna", "Anna <b>Maria</b>", "<em>Anna</em> &lt;b&gt;Maria&lt;/b&gt;"},
+		{"ann", "O'Anna", "O&#39;<em>Anna</em>"},
+		// ни одно слово не похоже - подсветки нет, а не текст без <em>
+		{"петров", "Ушаков", ""},
+		{"", "Ушаков", ""},
+	}
+	for _, tt := range tests {
+		if got := Highlight(tt.query, tt.text); got != tt.want {
+			t.Errorf("Highlight(%q, %q) = %q, want %q", tt.query, tt.text, got, tt.want)
+		}
+	}
+}
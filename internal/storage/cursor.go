@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"future_today/internal/cerrors"
+	"future_today/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+// cursor позиция в списке: значения полей сортировки и id последней
+// персоны страницы. Sort защищает от курсора, выданного для другого порядка
+type cursor struct {
+	Sort   string            `json:"sort"`
+	Values []json.RawMessage `json:"values"`
+	ID     uint              `json:"id"`
+}
+
+// EncodeCursor непрозрачный курсор на страницу после last. Пустой, если
+// порядок не поддерживает курсоры (сортировка по полю, которое бывает NULL)
+func EncodeCursor(sort []models.SortField, last *models.Person) string {
+	if !cursorSortable(sort) {
+		return ""
+	}
+	c := cursor{Sort: sortSpec(sort), ID: last.ID}
+	for _, s := range sort {
+		raw, err := json.Marshal(sortValue(last, s.Field))
+		if err != nil {
+			return ""
+		}
+		c.Values = append(c.Values, raw)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor значения полей сортировки и id из курсора
+func decodeCursor(raw string, sort []models.SortField) ([]any, uint, error) {
+	if !cursorSortable(sort) {
+		return nil, 0, fmt.Errorf("%w: sort by enriched_at doesn't support cursor", cerrors.ErrInvalidCursor)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, 0, cerrors.ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, 0, cerrors.ErrInvalidCursor
+	}
+	if c.Sort != sortSpec(sort) || len(c.Values) != len(sort) {
+		return nil, 0, fmt.Errorf("%w: cursor was issued for sort %q", cerrors.ErrInvalidCursor, c.Sort)
+	}
+	values := make([]any, len(sort))
+	for i, s := range sort {
+		if values[i], err = decodeValue(s.Field, c.Values[i]); err != nil {
+			return nil, 0, cerrors.ErrInvalidCursor
+		}
+	}
+	return values, c.ID, nil
+}
+
+func cursorSortable(sort []models.SortField) bool {
+	return !slices.ContainsFunc(sort, func(s models.SortField) bool { return s.Field == "enriched_at" })
+}
+
+func sortSpec(sort []models.SortField) string {
+	parts := make([]string, len(sort))
+	for i, s := range sort {
+		parts[i] = s.Field
+		if s.Desc {
+			parts[i] = "-" + s.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// keyset условие "строго после курсора" для порядка sort, id.
+// Раскрывается в (a > ?) OR (a = ? AND b < ?) OR (a = ? AND b = ? AND id > ?),
+// так как направления полей могут различаться
+func keyset(sort []models.SortField, values []any, id uint) (string, []any) {
+	fields := append(slices.Clone(sort), models.SortField{Field: "id"})
+	vals := append(slices.Clone(values), any(id))
+	var ors []string
+	var args []any
+	for i, f := range fields {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, sortColumns[fields[j].Field]+" = ?")
+			args = append(args, vals[j])
+		}
+		op := " > ?"
+		if f.Desc {
+			op = " < ?"
+		}
+		ands = append(ands, sortColumns[f.Field]+op)
+		args = append(args, vals[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return strings.Join(ors, " OR "), args
+}
+
+// sortValue значение поля сортировки персоны
+func sortValue(p *models.Person, field string) any {
+	switch field {
+	case "id":
+		return p.ID
+	case "name":
+		return p.Name
+	case "surname":
+		return p.Surname
+	case "patronymic":
+		return p.Patronymic
+	case "age":
+		return p.Age
+	case "gender":
+		return p.Gender
+	case "nationality":
+		return p.Nationality
+	case "gender_probability":
+		return p.GenderProbability
+	case "nationality_probability":
+		return p.NationalityProbability
+	case "created_at":
+		return p.CreatedAt
+	case "updated_at":
+		return p.UpdatedAt
+	case "enriched_at":
+		return p.EnrichedAt
+	}
+	return nil
+}
+
+// decodeValue читает значение курсора в тип поля
+func decodeValue(field string, raw json.RawMessage) (any, error) {
+	switch sortValue(&models.Person{}, field).(type) {
+	case string:
+		return decodeAs[string](raw)
+	case int:
+		return decodeAs[int](raw)
+	case uint:
+		return decodeAs[uint](raw)
+	case float64:
+		return decodeAs[float64](raw)
+	case time.Time:
+		return decodeAs[time.Time](raw)
+	}
+	return nil, fmt.Errorf("field %q can't be used in cursor", field)
+}
+
+func decodeAs[T any](raw json.RawMessage) (any, error) {
+	var v T
+	err := json.Unmarshal(raw, &v)
+	return v, err
+}
+
+// compareValues сравнивает значения одного поля, NULL больше любого
+// значения, как в postgres
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case string:
+		return cmp.Compare(a, b.(string))
+	case int:
+		return cmp.Compare(a, b.(int))
+	case uint:
+		return cmp.Compare(a, b.(uint))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case *time.Time:
+		b := b.(*time.Time)
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		case b == nil:
+			return -1
+		}
+		return a.Compare(*b)
+	}
+	return 0
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"future_today/internal/config"
+	"future_today/models"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newSQLiteDB временная sqlite-база со схемой из миграций
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := InitDb(&config.Config{DbDriver: DriverSQLite, DbPath: filepath.Join(t.TempDir(), "test.sqlite")})
+	if err != nil {
+		t.Fatalf("InitDb: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// newSQLiteRepo репозиторий на временной sqlite-базе
+func newSQLiteRepo(t *testing.T) *OrmRequestManager {
+	t.Helper()
+	return NewOrmRequestManager(newSQLiteDB(t))
+}
+
+// cursorPersons персоны с повторами значений, чтобы порядок решали
+// второе поле сортировки и id
+func cursorPersons() []models.Person {
+	return []models.Person{
+		{Name: "Anna", Surname: "Ivanova", Age: 30, GenderProbability: 0.9},
+		{Name: "Boris", Surname: "Petrov", Age: 30, GenderProbability: 0.8},
+		{Name: "Anna", Surname: "Sidorova", Age: 25, GenderProbability: 0.9},
+		{Name: "Vera", Surname: "Ivanova", Age: 41, GenderProbability: 0.7},
+		{Name: "Boris", Surname: "Alekseev", Age: 25, GenderProbability: 0.8},
+		{Name: "Anna", Surname: "Ivanova", Age: 30, GenderProbability: 0.6},
+		{Name: "Gleb", Surname: "Orlov", Age: 19, GenderProbability: 0.9},
+	}
+}
+
+func TestKeysetMixedDirections(t *testing.T) {
+	sort := []models.SortField{{Field: "age", Desc: true}, {Field: "name"}}
+	where, args := keyset(sort, []any{30, "Anna"}, 6)
+
+	wantWhere := "(age < ?) OR (age = ? AND name > ?) OR (age = ? AND name = ? AND id > ?)"
+	if where != wantWhere {
+		t.Errorf("where = %q, want %q", where, wantWhere)
+	}
+	wantArgs := []any{30, 30, "Anna", 30, "Anna", uint(6)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestAfterCursorMixedDirections(t *testing.T) {
+	sorts := [][]models.SortField{
+		{{Field: "age", Desc: true}, {Field: "name"}},
+		{{Field: "name"}, {Field: "gender_probability", Desc: true}},
+		{{Field: "surname", Desc: true}, {Field: "age"}, {Field: "name", Desc: true}},
+	}
+	for _, sort := range sorts {
+		t.Run(sortSpec(sort), func(t *testing.T) {
+			var persons []*models.Person
+			for i, p := range cursorPersons() {
+				p.ID = uint(i + 1)
+				persons = append(persons, &p)
+			}
+			sortPersons(persons, sort)
+			for i, p := range persons {
+				values := make([]any, len(sort))
+				for j, s := range sort {
+					values[j] = sortValue(p, s.Field)
+				}
+				if got := afterCursor(persons, sort, values, p.ID); got != i+1 {
+					t.Errorf("after person %d: index %d, want %d", p.ID, got, i+1)
+				}
+			}
+		})
+	}
+}
+
+// pageIDs проходит список курсором по limit персон и собирает id
+func pageIDs(t *testing.T, repo PersonRepository, sort []models.SortField, limit int) []uint {
+	t.Helper()
+	var ids []uint
+	filter := &models.PersonFilter{Sort: sort, Limit: limit}
+	for range 100 {
+		persons, err := repo.GetAll(filter)
+		if err != nil {
+			t.Fatalf("GetAll: %v", err)
+		}
+		for _, p := range persons {
+			ids = append(ids, p.ID)
+		}
+		if len(persons) < limit {
+			return ids
+		}
+		filter.Cursor = EncodeCursor(sort, &persons[len(persons)-1])
+	}
+	t.Fatal("pagination doesn't end")
+	return nil
+}
+
+func TestCursorOrderMatchesAcrossRepositories(t *testing.T) {
+	memory, _, _ := NewMemoryStorage()
+	sqlite := newSQLiteRepo(t)
+	for _, p := range cursorPersons() {
+		p.IsActive = true
+		for _, repo := range []PersonRepository{memory, sqlite} {
+			p := p
+			if err := repo.Create(&p); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		}
+	}
+
+	sorts := [][]models.SortField{
+		nil,
+		{{Field: "age", Desc: true}, {Field: "name"}},
+		{{Field: "name"}, {Field: "gender_probability", Desc: true}},
+		{{Field: "surname", Desc: true}, {Field: "age"}, {Field: "name", Desc: true}},
+	}
+	for _, sort := range sorts {
+		t.Run(sortSpec(sort), func(t *testing.T) {
+			want := pageIDs(t, memory, sort, 100)
+			if len(want) != len(cursorPersons()) {
+				t.Fatalf("memory returned %d persons, want %d", len(want), len(cursorPersons()))
+			}
+			for _, limit := range []int{1, 2, 3} {
+				if got := pageIDs(t, memory, sort, limit); !reflect.DeepEqual(got, want) {
+					t.Errorf("memory, limit %d: %v, want %v", limit, got, want)
+				}
+				if got := pageIDs(t, sqlite, sort, limit); !reflect.DeepEqual(got, want) {
+					t.Errorf("sqlite, limit %d: %v, want %v", limit, got, want)
+				}
+			}
+		})
+	}
+}
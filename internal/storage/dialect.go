@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"strings"
+	"sync"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"gorm.io/gorm"
+)
+
+var sqliteFunctions sync.Once
+
+// registerSQLiteFunctions добавляет в sqlite unicode_lower: встроенный
+// lower и LIKE не различают регистр только у ASCII, а имена у нас кириллицей
+func registerSQLiteFunctions() {
+	sqliteFunctions.Do(func() {
+		gosqlite.MustRegisterDeterministicScalarFunction("unicode_lower", 1,
+			func(ctx *gosqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+				switch v := args[0].(type) {
+				case string:
+					return strings.ToLower(v), nil
+				case []byte:
+					return strings.ToLower(string(v)), nil
+				}
+				return args[0], nil
+			})
+	})
+}
+
+// ilike условие "column содержит substr без учета регистра" на диалекте базы
+func ilike(query *gorm.DB, column, substr string) *gorm.DB {
+	if query.Dialector.Name() == DriverSQLite {
+		return query.Where("unicode_lower("+column+") LIKE ?", "%"+strings.ToLower(substr)+"%")
+	}
+	return query.Where(column+" ILIKE ?", "%"+substr+"%")
+}
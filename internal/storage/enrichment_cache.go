@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"future_today/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EnrichmentCache постоянный уровень кэша обогащения
+type EnrichmentCache struct {
+	db *gorm.DB
+}
+
+func NewEnrichmentCache(db *gorm.DB) *EnrichmentCache {
+	return &EnrichmentCache{db: db}
+}
+
+func (c *EnrichmentCache) GetEnrichment(provider, name, country string) ([]byte, time.Time, bool, error) {
+	var rec models.NameEnrichment
+	err := c.db.Where("provider = ? AND name = ? AND country = ?", provider, name, country).First(&rec).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, time.Time{}, false, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	return []byte(rec.Payload), rec.UpdatedAt, true, nil
+}
+
+func (c *EnrichmentCache) SetEnrichment(provider, name, country string, payload []byte) error {
+	rec := models.NameEnrichment{
+		Provider:  provider,
+		Name:      name,
+		Country:   country,
+		Payload:   string(payload),
+		UpdatedAt: time.Now(),
+	}
+	return c.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rec).Error
+}
+
+func (c *EnrichmentCache) InvalidateEnrichment(provider string) error {
+	return c.db.Where("provider = ?", provider).Delete(&models.NameEnrichment{}).Error
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"future_today/internal/filterexpr"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// filterClause переводит выражение фильтра в условие gorm. Колонки берутся
+// только из проверенных парсером полей, значения передаются параметрами
+func filterClause(e filterexpr.Expr, dialect string) clause.Expression {
+	switch e := e.(type) {
+	case *filterexpr.Logical:
+		sql := "(? OR ?)"
+		if e.And {
+			sql = "(? AND ?)"
+		}
+		return clause.Expr{SQL: sql, Vars: []any{filterClause(e.Left, dialect), filterClause(e.Right, dialect)}}
+	case *filterexpr.Not:
+		return clause.Expr{SQL: "NOT ?", Vars: []any{filterClause(e.X, dialect)}}
+	case *filterexpr.Compare:
+		column := clause.Column{Name: e.Field.Name}
+		switch e.Op {
+		case filterexpr.In:
+			return clause.Expr{SQL: "? IN ?", Vars: []any{column, e.Values}}
+		case filterexpr.Contains:
+			pattern := "%" + escapeLike(e.Values[0].(string)) + "%"
+			if dialect == DriverSQLite {
+				return clause.Expr{SQL: `unicode_lower(?) LIKE ? ESCAPE '\'`, Vars: []any{column, strings.ToLower(pattern)}}
+			}
+			return clause.Expr{SQL: `? ILIKE ? ESCAPE '\'`, Vars: []any{column, pattern}}
+		}
+		return clause.Expr{SQL: "? " + compareOps[e.Op] + " ?", Vars: []any{column, e.Values[0]}}
+	}
+	return clause.Expr{SQL: "FALSE"}
+}
+
+var compareOps = map[filterexpr.Op]string{
+	filterexpr.Eq: "=",
+	filterexpr.Ne: "<>",
+	filterexpr.Gt: ">",
+	filterexpr.Ge: ">=",
+	filterexpr.Lt: "<",
+	filterexpr.Le: "<=",
+}
+
+// escapeLike экранирует % и _, чтобы contains искал их буквально
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"future_today/internal/cerrors"
+	"future_today/internal/filterexpr"
+	"future_today/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestFilterClauseMatchesMatch выражения фильтра выбирают в sqlite те же
+// персоны, что filterexpr.Match в памяти, включая NULL и кириллицу
+func TestFilterClauseMatchesMatch(t *testing.T) {
+	enriched := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	earlier := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)
+	persons := []models.Person{
+		{Name: "Анна", Surname: "Иванова", Age: 30, Gender: "female", Nationality: "RU", GenderProbability: 0.95, EnrichedAt: &enriched},
+		{Name: "Boris", Surname: "Petrov", Age: 41, Gender: "male", Nationality: "BG", GenderProbability: 0.7, EnrichedAt: &earlier},
+		{Name: "Vera", Surname: "100%_sure", Age: 25, Gender: "female", EnrichmentPending: true},
+		{Name: "Глеб", Surname: "Орлов", Age: 19, Gender: "male", Nationality: "RU", GenderProbability: 0.5, EnrichedAt: &enriched},
+		{Name: "anna", Surname: "Smith", Age: 30, Nationality: "US", EnrichmentPending: true},
+	}
+	memory, _, _ := NewMemoryStorage()
+	sqlite := newSQLiteRepo(t)
+	for _, p := range persons {
+		p.IsActive = true
+		for _, repo := range []PersonRepository{memory, sqlite} {
+			p := p
+			if err := repo.Create(&p); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		}
+	}
+
+	exprs := []string{
+		`age ge 25 and age lt 41`,
+		`age gt 20 or gender eq "male" and nationality eq "RU"`,
+		`(age gt 20 or gender eq "male") and nationality eq "RU"`,
+		`not gender eq "female"`,
+		`name contains "АН"`,
+		`surname contains "орл"`,
+		`surname contains "%_"`,
+		`nationality in ("RU", "US")`,
+		`not nationality in ("RU", "US")`,
+		`gender_probability ge 0.7`,
+		`enriched_at ne "2024-06-01T12:00:00Z"`,
+		`enriched_at gt "2024-01-01"`,
+		`not enriched_at gt "2024-01-01"`,
+		`enriched_at lt "2024-01-01" or nationality eq ""`,
+		`not (enriched_at lt "2024-01-01" and age eq 30)`,
+		`not (enriched_at lt "2024-01-01" or age eq 30)`,
+		`id in (1, 3, 5) and not name eq "Vera"`,
+	}
+	for _, input := range exprs {
+		t.Run(input, func(t *testing.T) {
+			e, err := filterexpr.Parse(input, models.PersonFilterFields)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			var want []uint
+			for i, p := range persons {
+				p.ID = uint(i + 1)
+				if filterexpr.Match(e, p) {
+					want = append(want, uint(i+1))
+				}
+			}
+			for name, repo := range map[string]PersonRepository{"memory": memory, "sqlite": sqlite} {
+				got, err := repo.GetIDs(&models.PersonFilter{Expr: e})
+				if err != nil {
+					t.Fatalf("%s GetIDs: %v", name, err)
+				}
+				if len(got) == 0 {
+					got = nil
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: %v, Match: %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestPersonFilterFieldsHidesInternal служебные колонки нельзя использовать в фильтре
+func TestPersonFilterFieldsHidesInternal(t *testing.T) {
+	for _, field := range []string{"search_name", "country_hint", "is_active", "enrichment_pending", "deleted_at"} {
+		if _, err := filterexpr.Parse(field+` eq "x"`, models.PersonFilterFields); !errors.Is(err, cerrors.ErrInvalidFilter) {
+			t.Errorf("%s: error = %v, want ErrInvalidFilter", field, err)
+		}
+	}
+}
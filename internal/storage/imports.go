@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"cmp"
+	"errors"
+	"future_today/internal/cerrors"
+	"future_today/models"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ImportItem строка импорта и персона, которую из нее нужно создать.
+// Person nil, если строка не прошла проверку (Row.Error)
+type ImportItem struct {
+	Row    models.ImportRow
+	Person *models.Person
+}
+
+// ImportRepository загрузки персон из файлов
+type ImportRepository interface {
+	CreateImport(job *models.ImportJob) error
+	// UpdateImport сохраняет статус и счетчики загрузки
+	UpdateImport(job *models.ImportJob) error
+	// ImportBatch в одной транзакции создает персоны пачки, задачи их
+	// обогащения и строки отчета. Заполняет PersonID и EnrichmentJobID строк
+	ImportBatch(jobID uint, items []ImportItem) error
+	// GetImport возвращает cerrors.ErrNotFound, если загрузки нет
+	GetImport(id uint) (*models.ImportJob, error)
+	// EachImportRow обходит строки отчета загрузки по порядку строк файла
+	EachImportRow(jobID uint, fn func(row *models.ImportRow) error) error
+}
+
+var (
+	_ ImportRepository = (*ImportStore)(nil)
+	_ ImportRepository = (*MemoryImportStore)(nil)
+)
+
+// importChunkSize строк в одном INSERT пачки импорта, чтобы запрос
+// оставался в пределах числа параметров при любом IMPORT_BATCH_SIZE
+const importChunkSize = 500
+
+// ImportStore загрузки в таблицах import_jobs и import_rows
+type ImportStore struct {
+	db *gorm.DB
+}
+
+func NewImportStore(db *gorm.DB) *ImportStore {
+	return &ImportStore{db: db}
+}
+
+func (s *ImportStore) CreateImport(job *models.ImportJob) error {
+	return s.db.Create(job).Error
+}
+
+func (s *ImportStore) UpdateImport(job *models.ImportJob) error {
+	return s.db.Save(job).Error
+}
+
+func (s *ImportStore) ImportBatch(jobID uint, items []ImportItem) error {
+	var persons []*models.Person
+	for _, item := range items {
+		if item.Person != nil {
+			persons = append(persons, item.Person)
+		}
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		jobs := make([]models.EnrichmentJob, len(persons))
+		if len(persons) > 0 {
+			if err := tx.CreateInBatches(persons, importChunkSize).Error; err != nil {
+				return err
+			}
+			for i, p := range persons {
+				jobs[i] = models.EnrichmentJob{PersonID: p.ID, Status: models.JobPending}
+			}
+			if err := tx.CreateInBatches(&jobs, importChunkSize).Error; err != nil {
+				return err
+			}
+		}
+		rows := make([]models.ImportRow, len(items))
+		created := 0
+		for i := range items {
+			items[i].Row.ImportJobID = jobID
+			if items[i].Person != nil {
+				items[i].Row.PersonID = &items[i].Person.ID
+				items[i].Row.EnrichmentJobID = &jobs[created].ID
+				created++
+			}
+			rows[i] = items[i].Row
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&rows, importChunkSize).Error
+	})
+}
+
+func (s *ImportStore) GetImport(id uint) (*models.ImportJob, error) {
+	var job models.ImportJob
+	err := s.db.First(&job, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cerrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *ImportStore) EachImportRow(jobID uint, fn func(row *models.ImportRow) error) error {
+	rows, err := s.db.Model(&models.ImportRow{}).Where("import_job_id = ?", jobID).Order("line, id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row models.ImportRow
+		if err := s.db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// MemoryImportStore ImportRepository в памяти, общий с хранилищем персон
+type MemoryImportStore struct {
+	db *memoryDB
+}
+
+func (s *MemoryImportStore) CreateImport(job *models.ImportJob) error {
+	defer s.db.lock(false)()
+	s.db.nextImport++
+	job.ID = s.db.nextImport
+	job.CreatedAt = time.Now()
+	stored := *job
+	s.db.imports[job.ID] = &stored
+	return nil
+}
+
+func (s *MemoryImportStore) UpdateImport(job *models.ImportJob) error {
+	defer s.db.lock(false)()
+	if _, ok := s.db.imports[job.ID]; !ok {
+		return cerrors.ErrNotFound
+	}
+	stored := *job
+	s.db.imports[job.ID] = &stored
+	return nil
+}
+
+func (s *MemoryImportStore) ImportBatch(jobID uint, items []ImportItem) error {
+	defer s.db.lock(false)()
+	for i := range items {
+		row := &items[i].Row
+		row.ImportJobID = jobID
+		if p := items[i].Person; p != nil {
+			s.db.createPerson(p)
+			job := &models.EnrichmentJob{PersonID: p.ID, Status: models.JobPending}
+			s.db.createJob(job)
+			row.PersonID, row.EnrichmentJobID = &p.ID, &job.ID
+		}
+		s.db.nextImportRow++
+		row.ID = s.db.nextImportRow
+		s.db.importRows[jobID] = append(s.db.importRows[jobID], *row)
+	}
+	return nil
+}
+
+func (s *MemoryImportStore) GetImport(id uint) (*models.ImportJob, error) {
+	defer s.db.lock(false)()
+	job, ok := s.db.imports[id]
+	if !ok {
+		return nil, cerrors.ErrNotFound
+	}
+	cp := *job
+	return &cp, nil
+}
+
+func (s *MemoryImportStore) EachImportRow(jobID uint, fn func(row *models.ImportRow) error) error {
+	unlock := s.db.lock(false)
+	rows := slices.Clone(s.db.importRows[jobID])
+	unlock()
+	slices.SortStableFunc(rows, func(a, b models.ImportRow) int { return cmp.Compare(a.Line, b.Line) })
+	for i := range rows {
+		if err := fn(&rows[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
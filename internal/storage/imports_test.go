@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"fmt"
+	"future_today/models"
+	"testing"
+)
+
+// TestImportBatchLargerThanChunk пачка больше importChunkSize: строки
+// отчета ссылаются на свои персоны и задачи
+func TestImportBatchLargerThanChunk(t *testing.T) {
+	db := newSQLiteDB(t)
+	memoryRepo, _, memoryImports := NewMemoryStorage()
+	backends := []struct {
+		name    string
+		repo    PersonRepository
+		imports ImportRepository
+	}{
+		{"memory", memoryRepo, memoryImports},
+		{"sqlite", NewOrmRequestManager(db), NewImportStore(db)},
+	}
+	n := 2*importChunkSize + 7
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			job := &models.ImportJob{Status: models.ImportRunning, Format: "csv"}
+			if err := b.imports.CreateImport(job); err != nil {
+				t.Fatalf("CreateImport: %v", err)
+			}
+			items := make([]ImportItem, n)
+			for i := range items {
+				items[i].Row.Line = i + 2
+				if i%3 == 0 {
+					items[i].Row.Error = "surname is required"
+					continue
+				}
+				items[i].Person = &models.Person{Name: fmt.Sprintf("name%d", i), Surname: "Import", IsActive: true}
+			}
+			if err := b.imports.ImportBatch(job.ID, items); err != nil {
+				t.Fatalf("ImportBatch: %v", err)
+			}
+
+			// в sqlite одно соединение: строки отчета собираются до чтения персон
+			var rows []models.ImportRow
+			err := b.imports.EachImportRow(job.ID, func(row *models.ImportRow) error {
+				rows = append(rows, *row)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("EachImportRow: %v", err)
+			}
+			if len(rows) != n {
+				t.Fatalf("%d report rows, want %d", len(rows), n)
+			}
+			jobs := map[uint]bool{}
+			for i, row := range rows {
+				if row.Line != i+2 {
+					t.Fatalf("row %d: line %d", i, row.Line)
+				}
+				if i%3 == 0 {
+					if row.PersonID != nil || row.EnrichmentJobID != nil || row.Error == "" {
+						t.Errorf("line %d: failed row = %+v", row.Line, row)
+					}
+					continue
+				}
+				if row.PersonID == nil || row.EnrichmentJobID == nil {
+					t.Fatalf("line %d: row = %+v without person or job", row.Line, row)
+				}
+				person, err := b.repo.GetByID(*row.PersonID)
+				if err != nil {
+					t.Fatalf("GetByID: %v", err)
+				}
+				if want := fmt.Sprintf("name%d", i); person.Name != want {
+					t.Errorf("line %d: person %q, want %q", row.Line, person.Name, want)
+				}
+				if jobs[*row.EnrichmentJobID] {
+					t.Errorf("line %d: job %d shared with another row", row.Line, *row.EnrichmentJobID)
+				}
+				jobs[*row.EnrichmentJobID] = true
+			}
+		})
+	}
+
+	// в sqlite задача обогащения ссылается на персону той же строки
+	var mismatched int64
+	err := db.Table("import_rows").Joins("JOIN enrichment_jobs ON enrichment_jobs.id = import_rows.enrichment_job_id").
+		Where("enrichment_jobs.person_id <> import_rows.person_id").Count(&mismatched).Error
+	if err != nil || mismatched != 0 {
+		t.Errorf("%d jobs of another person (%v)", mismatched, err)
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"future_today/internal/cerrors"
+	"future_today/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobQueue очередь задач обогащения в таблице enrichment_jobs
+type JobQueue struct {
+	db *gorm.DB
+}
+
+func NewJobQueue(db *gorm.DB) *JobQueue {
+	return &JobQueue{db: db}
+}
+
+// EnqueueWithPerson сохраняет персону и задачу на ее обогащение
+// в одной транзакции, чтобы задача не потерялась
+func (q *JobQueue) EnqueueWithPerson(person *models.Person) (*models.EnrichmentJob, error) {
+	job := &models.EnrichmentJob{Status: models.JobPending}
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(person).Error; err != nil {
+			return err
+		}
+		job.PersonID = person.ID
+		return tx.Create(job).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Enqueue ставит задачи обогащения для уже сохраненных персон,
+// force - перезаписать и заданные вручную поля
+func (q *JobQueue) Enqueue(personIDs []uint, force bool) ([]models.EnrichmentJob, error) {
+	if len(personIDs) == 0 {
+		return nil, nil
+	}
+	jobs := make([]models.EnrichmentJob, len(personIDs))
+	for i, id := range personIDs {
+		jobs[i] = models.EnrichmentJob{PersonID: id, Status: models.JobPending, Force: force}
+	}
+	if err := q.db.CreateInBatches(&jobs, 500).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Claim берет до limit самых старых свободных задач в аренду на lease
+func (q *JobQueue) Claim(lease time.Duration, limit int) ([]models.EnrichmentJob, error) {
+	var jobs []models.EnrichmentJob
+	now := time.Now()
+	err := q.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND locked_until < ?)", models.JobPending, models.JobRunning, now).
+			Order("id").Limit(limit).Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+		lockedUntil := now.Add(lease)
+		ids := make([]uint, len(jobs))
+		for i := range jobs {
+			ids[i] = jobs[i].ID
+			jobs[i].Status = models.JobRunning
+			jobs[i].Attempts++
+			jobs[i].LockedUntil = &lockedUntil
+		}
+		return tx.Model(&models.EnrichmentJob{}).Where("id IN ?", ids).Updates(map[string]any{
+			"status":       models.JobRunning,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"locked_until": lockedUntil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (q *JobQueue) Complete(job *models.EnrichmentJob) error {
+	job.Status = models.JobDone
+	job.Error = ""
+	job.LockedUntil = nil
+	return q.db.Save(job).Error
+}
+
+// Fail возвращает задачу в очередь или помечает ее проваленной,
+// если попытки кончились
+func (q *JobQueue) Fail(job *models.EnrichmentJob, cause error, maxAttempts int) error {
+	job.Status = models.JobPending
+	if job.Attempts >= maxAttempts {
+		job.Status = models.JobFailed
+	}
+	job.Error = cause.Error()
+	job.LockedUntil = nil
+	return q.db.Save(job).Error
+}
+
+func (q *JobQueue) GetJob(id uint) (*models.EnrichmentJob, error) {
+	var job models.EnrichmentJob
+	err := q.db.First(&job, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cerrors.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,467 @@
+package storage
+
+import (
+	"future_today/internal/cerrors"
+	"future_today/internal/filterexpr"
+	"future_today/models"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryDB общее состояние хранилища в памяти: персоны, очередь задач
+// и загрузки. Загрузки в транзакции персон не участвуют
+type memoryDB struct {
+	mu         sync.Mutex
+	persons    map[uint]*models.Person
+	jobs       map[uint]*models.EnrichmentJob
+	nextPerson uint
+	nextJob    uint
+
+	imports       map[uint]*models.ImportJob
+	importRows    map[uint][]models.ImportRow
+	nextImport    uint
+	nextImportRow uint
+}
+
+// NewMemoryStorage хранилище персон, очередь задач и загрузки в памяти
+// процесса. Данные живут до перезапуска
+func NewMemoryStorage() (*MemoryPersonRepository, *MemoryJobQueue, *MemoryImportStore) {
+	db := &memoryDB{
+		persons:    map[uint]*models.Person{},
+		jobs:       map[uint]*models.EnrichmentJob{},
+		imports:    map[uint]*models.ImportJob{},
+		importRows: map[uint][]models.ImportRow{},
+	}
+	return &MemoryPersonRepository{db: db}, &MemoryJobQueue{db: db}, &MemoryImportStore{db: db}
+}
+
+// lock берет блокировку, внутри транзакции она уже взята
+func (db *memoryDB) lock(tx bool) func() {
+	if tx {
+		return func() {}
+	}
+	db.mu.Lock()
+	return db.mu.Unlock
+}
+
+func (db *memoryDB) snapshot() *memoryDB {
+	snap := &memoryDB{
+		persons:    make(map[uint]*models.Person, len(db.persons)),
+		jobs:       make(map[uint]*models.EnrichmentJob, len(db.jobs)),
+		nextPerson: db.nextPerson,
+		nextJob:    db.nextJob,
+	}
+	for id, p := range db.persons {
+		snap.persons[id] = copyPerson(p)
+	}
+	for id, j := range db.jobs {
+		job := *j
+		snap.jobs[id] = &job
+	}
+	return snap
+}
+
+func (db *memoryDB) restore(snap *memoryDB) {
+	db.persons, db.jobs = snap.persons, snap.jobs
+	db.nextPerson, db.nextJob = snap.nextPerson, snap.nextJob
+}
+
+func (db *memoryDB) createPerson(person *models.Person) {
+	db.nextPerson++
+	now := time.Now()
+	person.ID = db.nextPerson
+	person.CreatedAt, person.UpdatedAt = now, now
+	for i := range person.Countries {
+		person.Countries[i].PersonID = person.ID
+	}
+	for i := range person.Provenance {
+		person.Provenance[i].PersonID = person.ID
+	}
+	db.persons[person.ID] = copyPerson(person)
+}
+
+func (db *memoryDB) createJob(job *models.EnrichmentJob) {
+	db.nextJob++
+	now := time.Now()
+	job.ID = db.nextJob
+	job.CreatedAt, job.UpdatedAt = now, now
+	stored := *job
+	db.jobs[job.ID] = &stored
+}
+
+// copyPerson копия с собственными срезами, чтобы вызывающий не менял хранилище
+func copyPerson(p *models.Person) *models.Person {
+	cp := *p
+	cp.Countries = slices.Clone(p.Countries)
+	cp.Provenance = slices.Clone(p.Provenance)
+	return &cp
+}
+
+// MemoryPersonRepository PersonRepository в памяти. Поддерживает те же
+// фильтры, что и OrmRequestManager, транзакции выполняются по одной
+type MemoryPersonRepository struct {
+	db *memoryDB
+	tx bool
+}
+
+func (r *MemoryPersonRepository) Create(person *models.Person) error {
+	defer r.db.lock(r.tx)()
+	r.db.createPerson(person)
+	return nil
+}
+
+func (r *MemoryPersonRepository) GetByID(id uint) (*models.Person, error) {
+	defer r.db.lock(r.tx)()
+	p, ok := r.db.persons[id]
+	if !ok {
+		return nil, cerrors.ErrNotFound
+	}
+	return copyPerson(p), nil
+}
+
+func (r *MemoryPersonRepository) GetByIDs(ids []uint) ([]models.Person, error) {
+	defer r.db.lock(r.tx)()
+	var persons []models.Person
+	for _, p := range r.db.sorted() {
+		if slices.Contains(ids, p.ID) {
+			persons = append(persons, *copyPerson(p))
+		}
+	}
+	return persons, nil
+}
+
+// Each копирует персон под блокировкой и вызывает fn уже без нее, чтобы
+// медленный получатель выгрузки не держал хранилище
+func (r *MemoryPersonRepository) Each(filter *models.PersonFilter, fn func(person *models.Person) error) error {
+	if err := validateSort(filter.Sort); err != nil {
+		return err
+	}
+	unlock := r.db.lock(r.tx)
+	matched := r.db.filtered(filter)
+	sortPersons(matched, filter.Sort)
+	persons := make([]*models.Person, len(matched))
+	for i, p := range matched {
+		persons[i] = copyPerson(p)
+	}
+	unlock()
+
+	for _, p := range persons {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryPersonRepository) GetAll(filter *models.PersonFilter) ([]models.Person, error) {
+	if err := validateSort(filter.Sort); err != nil {
+		return nil, err
+	}
+	var values []any
+	var id uint
+	if filter.Cursor != "" {
+		var err error
+		if values, id, err = decodeCursor(filter.Cursor, filter.Sort); err != nil {
+			return nil, err
+		}
+	}
+	defer r.db.lock(r.tx)()
+	matched := r.db.filtered(filter)
+	sortPersons(matched, filter.Sort)
+	start := min(max(filter.Offset, 0), len(matched))
+	if filter.Cursor != "" {
+		start = afterCursor(matched, filter.Sort, values, id)
+	}
+	end := len(matched)
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, end)
+	}
+	persons := make([]models.Person, 0, end-start)
+	for _, p := range matched[start:end] {
+		persons = append(persons, *copyPerson(p))
+	}
+	return persons, nil
+}
+
+func (r *MemoryPersonRepository) GetIDs(filter *models.PersonFilter) ([]uint, error) {
+	defer r.db.lock(r.tx)()
+	matched := r.db.filtered(filter)
+	ids := make([]uint, len(matched))
+	for i, p := range matched {
+		ids[i] = p.ID
+	}
+	return ids, nil
+}
+
+func (r *MemoryPersonRepository) Count(filter *models.PersonFilter) (int64, error) {
+	defer r.db.lock(r.tx)()
+	return int64(len(r.db.filtered(filter))), nil
+}
+
+func (r *MemoryPersonRepository) GetStaleIDs(before time.Time, limit int) ([]uint, error) {
+	defer r.db.lock(r.tx)()
+	queued := map[uint]bool{}
+	for _, job := range r.db.jobs {
+		if job.Status == models.JobPending || job.Status == models.JobRunning {
+			queued[job.PersonID] = true
+		}
+	}
+	var stale []*models.Person
+	for _, p := range r.db.sorted() {
+		if p.IsActive && !queued[p.ID] && (p.EnrichedAt == nil || p.EnrichedAt.Before(before)) {
+			stale = append(stale, p)
+		}
+	}
+	// как ORDER BY enriched_at NULLS FIRST, id
+	sort.SliceStable(stale, func(i, j int) bool {
+		a, b := stale[i].EnrichedAt, stale[j].EnrichedAt
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Before(*b)
+	})
+	ids := make([]uint, 0, min(limit, len(stale)))
+	for _, p := range stale[:min(limit, len(stale))] {
+		ids = append(ids, p.ID)
+	}
+	return ids, nil
+}
+
+func (r *MemoryPersonRepository) GetPendingEnrichment(limit int) ([]models.Person, error) {
+	defer r.db.lock(r.tx)()
+	var persons []models.Person
+	for _, p := range r.db.sorted() {
+		if len(persons) == limit {
+			break
+		}
+		if p.IsActive && p.EnrichmentPending {
+			persons = append(persons, *copyPerson(p))
+		}
+	}
+	return persons, nil
+}
+
+// Update как у OrmRequestManager: страны не трогает, происхождение
+// полей обновляет по (person_id, field)
+func (r *MemoryPersonRepository) Update(person *models.Person) error {
+	defer r.db.lock(r.tx)()
+	stored, ok := r.db.persons[person.ID]
+	if !ok {
+		return cerrors.ErrNotFound
+	}
+	updated := copyPerson(person)
+	updated.Countries = stored.Countries
+	updated.Provenance = stored.Provenance
+	for _, fp := range person.Provenance {
+		fp.PersonID = person.ID
+		updated.SetProvenance(fp)
+	}
+	updated.UpdatedAt = time.Now()
+	person.UpdatedAt = updated.UpdatedAt
+	r.db.persons[person.ID] = updated
+	return nil
+}
+
+func (r *MemoryPersonRepository) UpdateWithCountries(person *models.Person) error {
+	return r.Transaction(func(repo PersonRepository) error {
+		if err := repo.Update(person); err != nil {
+			return err
+		}
+		tx := repo.(*MemoryPersonRepository)
+		for i := range person.Countries {
+			person.Countries[i].PersonID = person.ID
+		}
+		tx.db.persons[person.ID].Countries = slices.Clone(person.Countries)
+		return nil
+	})
+}
+
+func (r *MemoryPersonRepository) Delete(id uint) error {
+	defer r.db.lock(r.tx)()
+	if p, ok := r.db.persons[id]; ok {
+		p.IsActive = false
+	}
+	return nil
+}
+
+func (r *MemoryPersonRepository) Transaction(fn func(repo PersonRepository) error) error {
+	if r.tx {
+		return fn(r)
+	}
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	snap := r.db.snapshot()
+	if err := fn(&MemoryPersonRepository{db: r.db, tx: true}); err != nil {
+		r.db.restore(snap)
+		return err
+	}
+	return nil
+}
+
+// sorted персоны по id
+func (db *memoryDB) sorted() []*models.Person {
+	persons := make([]*models.Person, 0, len(db.persons))
+	for _, p := range db.persons {
+		persons = append(persons, p)
+	}
+	sort.Slice(persons, func(i, j int) bool { return persons[i].ID < persons[j].ID })
+	return persons
+}
+
+// filtered активные персоны под фильтром, повторяет applyFilter
+func (db *memoryDB) filtered(filter *models.PersonFilter) []*models.Person {
+	var out []*models.Person
+	for _, p := range db.sorted() {
+		if p.IsActive && matches(p, filter) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func matches(p *models.Person, filter *models.PersonFilter) bool {
+	contains := func(value string, substr *string) bool {
+		return substr == nil || strings.Contains(strings.ToLower(value), strings.ToLower(*substr))
+	}
+	switch {
+	case !contains(p.Name, filter.Name),
+		!contains(p.Surname, filter.Surname),
+		!contains(p.Patronymic, filter.Patronymic),
+		!contains(p.Gender, filter.Gender),
+		!contains(p.Nationality, filter.Nationality),
+		filter.MinAge != nil && p.Age < *filter.MinAge,
+		filter.MaxAge != nil && p.Age > *filter.MaxAge,
+		filter.MinGenderProbability != nil && p.GenderProbability < *filter.MinGenderProbability,
+		filter.MinNationalityProbability != nil && p.NationalityProbability < *filter.MinNationalityProbability,
+		filter.Expr != nil && !filterexpr.Match(filter.Expr, p):
+		return false
+	}
+	return true
+}
+
+// sortPersons как ORDER BY у OrmRequestManager, persons уже по id
+func sortPersons(persons []*models.Person, sort []models.SortField) {
+	slices.SortStableFunc(persons, func(a, b *models.Person) int {
+		for _, s := range sort {
+			c := compareValues(sortValue(a, s.Field), sortValue(b, s.Field))
+			if s.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+// afterCursor индекс первой персоны строго после курсора
+func afterCursor(persons []*models.Person, sort []models.SortField, values []any, id uint) int {
+	i, _ := slices.BinarySearchFunc(persons, 0, func(p *models.Person, _ int) int {
+		for j, s := range sort {
+			c := compareValues(sortValue(p, s.Field), values[j])
+			if s.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		if p.ID <= id {
+			return -1
+		}
+		return 1
+	})
+	return i
+}
+
+// MemoryJobQueue JobRepository в памяти, делит состояние с MemoryPersonRepository
+type MemoryJobQueue struct {
+	db *memoryDB
+}
+
+func (q *MemoryJobQueue) EnqueueWithPerson(person *models.Person) (*models.EnrichmentJob, error) {
+	defer q.db.lock(false)()
+	q.db.createPerson(person)
+	job := &models.EnrichmentJob{PersonID: person.ID, Status: models.JobPending}
+	q.db.createJob(job)
+	return job, nil
+}
+
+func (q *MemoryJobQueue) Enqueue(personIDs []uint, force bool) ([]models.EnrichmentJob, error) {
+	if len(personIDs) == 0 {
+		return nil, nil
+	}
+	defer q.db.lock(false)()
+	jobs := make([]models.EnrichmentJob, len(personIDs))
+	for i, id := range personIDs {
+		jobs[i] = models.EnrichmentJob{PersonID: id, Status: models.JobPending, Force: force}
+		q.db.createJob(&jobs[i])
+	}
+	return jobs, nil
+}
+
+func (q *MemoryJobQueue) Claim(lease time.Duration, limit int) ([]models.EnrichmentJob, error) {
+	defer q.db.lock(false)()
+	var free []*models.EnrichmentJob
+	now := time.Now()
+	for _, job := range q.db.jobs {
+		expired := job.Status == models.JobRunning && job.LockedUntil != nil && job.LockedUntil.Before(now)
+		if job.Status == models.JobPending || expired {
+			free = append(free, job)
+		}
+	}
+	sort.Slice(free, func(i, j int) bool { return free[i].ID < free[j].ID })
+
+	lockedUntil := now.Add(lease)
+	jobs := make([]models.EnrichmentJob, 0, min(limit, len(free)))
+	for _, job := range free[:min(limit, len(free))] {
+		job.Status = models.JobRunning
+		job.Attempts++
+		job.LockedUntil = &lockedUntil
+		job.UpdatedAt = now
+		jobs = append(jobs, *job)
+	}
+	return jobs, nil
+}
+
+func (q *MemoryJobQueue) Complete(job *models.EnrichmentJob) error {
+	job.Status = models.JobDone
+	job.Error = ""
+	job.LockedUntil = nil
+	return q.save(job)
+}
+
+func (q *MemoryJobQueue) Fail(job *models.EnrichmentJob, cause error, maxAttempts int) error {
+	job.Status = models.JobPending
+	if job.Attempts >= maxAttempts {
+		job.Status = models.JobFailed
+	}
+	job.Error = cause.Error()
+	job.LockedUntil = nil
+	return q.save(job)
+}
+
+func (q *MemoryJobQueue) save(job *models.EnrichmentJob) error {
+	defer q.db.lock(false)()
+	if _, ok := q.db.jobs[job.ID]; !ok {
+		return cerrors.ErrNotFound
+	}
+	job.UpdatedAt = time.Now()
+	stored := *job
+	q.db.jobs[job.ID] = &stored
+	return nil
+}
+
+func (q *MemoryJobQueue) GetJob(id uint) (*models.EnrichmentJob, error) {
+	defer q.db.lock(false)()
+	job, ok := q.db.jobs[id]
+	if !ok {
+		return nil, cerrors.ErrNotFound
+	}
+	cp := *job
+	return &cp, nil
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"future_today/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.sqlite")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestUpDownSQLite(t *testing.T) {
+	db := openSQLite(t)
+	m, err := New(db)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(applied) != len(m.migrations) {
+		t.Errorf("applied %d of %d migrations", len(applied), len(m.migrations))
+	}
+	if !db.Migrator().HasColumn("people", "search_name") {
+		t.Error("people.search_name wasn't created")
+	}
+	if again, err := m.Up(); err != nil || len(again) != 0 {
+		t.Errorf("second Up = %d migrations, %v, want none", len(again), err)
+	}
+	if _, err := m.Down(len(m.migrations)); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if db.Migrator().HasTable("people") {
+		t.Error("people remains after Down")
+	}
+}
+
+// базу из DB_AUTO_MIGRATE можно перевести на миграции
+func TestUpAfterAutoMigrateSQLite(t *testing.T) {
+	db := openSQLite(t)
+	err := db.AutoMigrate(&models.Person{}, &models.PersonCountry{}, &models.FieldProvenance{}, &models.NameEnrichment{},
+		&models.EnrichmentJob{}, &models.ImportJob{}, &models.ImportRow{})
+	if err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	m, err := New(db)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up after AutoMigrate: %v", err)
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("migration %d_%s isn't applied", s.Version, s.Name)
+		}
+	}
+}
+
+func TestSkipExistingColumns(t *testing.T) {
+	db := openSQLite(t)
+	if err := db.Exec("CREATE TABLE people (id integer, name text)").Error; err != nil {
+		t.Fatal(err)
+	}
+	sql := "ALTER TABLE people ADD COLUMN name text;\nALTER TABLE people ADD COLUMN age integer;\n"
+	want := "\nALTER TABLE people ADD COLUMN age integer;\n"
+	if got := skipExistingColumns(db, sql); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+-- Схема, которую раньше создавал AutoMigrate. IF NOT EXISTS, чтобы
+-- базы, созданные AutoMigrate, приняли миграцию без изменений
+CREATE TABLE IF NOT EXISTS people (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text,
+    surname text,
+    patronymic text,
+    country_hint varchar(2),
+    age bigint,
+    nationality text,
+    gender text,
+    is_active boolean DEFAULT true,
+    enrichment_pending boolean,
+    enriched_at timestamptz,
+    age_count bigint,
+    gender_probability decimal,
+    nationality_probability decimal
+);
+-- у таблицы из AutoMigrate прежних версий новых колонок нет
+ALTER TABLE people ADD COLUMN IF NOT EXISTS country_hint varchar(2);
+ALTER TABLE people ADD COLUMN IF NOT EXISTS enrichment_pending boolean;
+ALTER TABLE people ADD COLUMN IF NOT EXISTS enriched_at timestamptz;
+ALTER TABLE people ADD COLUMN IF NOT EXISTS age_count bigint;
+ALTER TABLE people ADD COLUMN IF NOT EXISTS gender_probability decimal;
+ALTER TABLE people ADD COLUMN IF NOT EXISTS nationality_probability decimal;
+CREATE INDEX IF NOT EXISTS idx_people_deleted_at ON people (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_people_name ON people (name);
+CREATE INDEX IF NOT EXISTS idx_people_surname ON people (surname);
+CREATE INDEX IF NOT EXISTS idx_people_enrichment_pending ON people (enrichment_pending);
+CREATE INDEX IF NOT EXISTS idx_people_enriched_at ON people (enriched_at);
+
+CREATE TABLE IF NOT EXISTS person_countries (
+    id bigserial PRIMARY KEY,
+    person_id bigint,
+    country_id varchar(2),
+    probability decimal,
+    rank bigint,
+    CONSTRAINT fk_people_countries FOREIGN KEY (person_id) REFERENCES people (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_person_countries_person_id ON person_countries (person_id);
+
+CREATE TABLE IF NOT EXISTS field_provenances (
+    id bigserial PRIMARY KEY,
+    person_id bigint,
+    field varchar(32),
+    source varchar(16),
+    provider text,
+    confidence decimal,
+    updated_at timestamptz,
+    CONSTRAINT fk_people_provenance FOREIGN KEY (person_id) REFERENCES people (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_provenance_person_field ON field_provenances (person_id, field);
+
+CREATE TABLE IF NOT EXISTS name_enrichment (
+    provider text,
+    name text,
+    country varchar(2),
+    payload text,
+    updated_at timestamptz,
+    PRIMARY KEY (provider, name, country)
+);
+
+CREATE TABLE IF NOT EXISTS enrichment_jobs (
+    id bigserial PRIMARY KEY,
+    person_id bigint,
+    status varchar(16),
+    attempts bigint,
+    force boolean,
+    error text,
+    locked_until timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_person_id ON enrichment_jobs (person_id);
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_status ON enrichment_jobs (status);
@@ -0,0 +1,3 @@
+-- расширение pg_trgm не удаляется: им могут пользоваться другие схемы
+DROP INDEX IF EXISTS idx_people_search_fts;
+DROP INDEX IF EXISTS idx_people_search_trgm;
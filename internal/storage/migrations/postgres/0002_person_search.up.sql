@@ -0,0 +1,9 @@
+-- Поиск персон: триграммы для опечаток и полнотекстовый индекс.
+-- Выражение должно совпадать с searchDocument в storage/search.go
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_people_search_trgm ON people
+    USING gin ((coalesce(name, '') || ' ' || coalesce(surname, '') || ' ' || coalesce(patronymic, '')) gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_people_search_fts ON people
+    USING gin (to_tsvector('simple', coalesce(name, '') || ' ' || coalesce(surname, '') || ' ' || coalesce(patronymic, '')));
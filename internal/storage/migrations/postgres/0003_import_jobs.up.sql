@@ -0,0 +1,23 @@
+-- Загрузки персон из файлов и результат по каждой строке
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id bigserial PRIMARY KEY,
+    status varchar(16),
+    format varchar(16),
+    filename text,
+    total bigint,
+    created bigint,
+    failed bigint,
+    error text,
+    created_at timestamptz,
+    finished_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS import_rows (
+    id bigserial PRIMARY KEY,
+    import_job_id bigint REFERENCES import_jobs (id) ON DELETE CASCADE,
+    line bigint,
+    person_id bigint,
+    enrichment_job_id bigint,
+    error text
+);
+CREATE INDEX IF NOT EXISTS idx_import_rows_import_job_id ON import_rows (import_job_id);
@@ -0,0 +1,4 @@
+-- Имя латиницей для поиска по транслитерации, см. search.Latin.
+-- Существующие строки заполняет storage.InitDb
+ALTER TABLE people ADD COLUMN IF NOT EXISTS search_name text;
+CREATE INDEX IF NOT EXISTS idx_people_search_name_trgm ON people USING gin (search_name gin_trgm_ops);
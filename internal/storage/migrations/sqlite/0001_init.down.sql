@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS enrichment_jobs;
+DROP TABLE IF EXISTS name_enrichment;
+DROP TABLE IF EXISTS field_provenances;
+DROP TABLE IF EXISTS person_countries;
+DROP TABLE IF EXISTS people;
@@ -0,0 +1,71 @@
+-- Схема, которую раньше создавал AutoMigrate. IF NOT EXISTS, чтобы
+-- базы, созданные AutoMigrate, приняли миграцию без изменений
+CREATE TABLE IF NOT EXISTS people (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    name text,
+    surname text,
+    patronymic text,
+    country_hint text,
+    age integer,
+    nationality text,
+    gender text,
+    is_active numeric DEFAULT true,
+    enrichment_pending numeric,
+    enriched_at datetime,
+    age_count integer,
+    gender_probability real,
+    nationality_probability real
+);
+CREATE INDEX IF NOT EXISTS idx_people_deleted_at ON people (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_people_name ON people (name);
+CREATE INDEX IF NOT EXISTS idx_people_surname ON people (surname);
+CREATE INDEX IF NOT EXISTS idx_people_enrichment_pending ON people (enrichment_pending);
+CREATE INDEX IF NOT EXISTS idx_people_enriched_at ON people (enriched_at);
+
+CREATE TABLE IF NOT EXISTS person_countries (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    person_id integer,
+    country_id text,
+    probability real,
+    rank integer,
+    CONSTRAINT fk_people_countries FOREIGN KEY (person_id) REFERENCES people (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_person_countries_person_id ON person_countries (person_id);
+
+CREATE TABLE IF NOT EXISTS field_provenances (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    person_id integer,
+    field text,
+    source text,
+    provider text,
+    confidence real,
+    updated_at datetime,
+    CONSTRAINT fk_people_provenance FOREIGN KEY (person_id) REFERENCES people (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_provenance_person_field ON field_provenances (person_id, field);
+
+CREATE TABLE IF NOT EXISTS name_enrichment (
+    provider text,
+    name text,
+    country text,
+    payload text,
+    updated_at datetime,
+    PRIMARY KEY (provider, name, country)
+);
+
+CREATE TABLE IF NOT EXISTS enrichment_jobs (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    person_id integer,
+    status text,
+    attempts integer,
+    force numeric,
+    error text,
+    locked_until datetime,
+    created_at datetime,
+    updated_at datetime
+);
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_person_id ON enrichment_jobs (person_id);
+CREATE INDEX IF NOT EXISTS idx_enrichment_jobs_status ON enrichment_jobs (status);
@@ -0,0 +1,23 @@
+-- Загрузки персон из файлов и результат по каждой строке
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    status text,
+    format text,
+    filename text,
+    total integer,
+    created integer,
+    failed integer,
+    error text,
+    created_at datetime,
+    finished_at datetime
+);
+
+CREATE TABLE IF NOT EXISTS import_rows (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    import_job_id integer REFERENCES import_jobs (id) ON DELETE CASCADE,
+    line integer,
+    person_id integer,
+    enrichment_job_id integer,
+    error text
+);
+CREATE INDEX IF NOT EXISTS idx_import_rows_import_job_id ON import_rows (import_job_id);
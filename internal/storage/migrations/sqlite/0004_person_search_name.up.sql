@@ -0,0 +1,3 @@
+-- Имя латиницей для поиска по транслитерации, см. search.Latin.
+-- Существующие строки заполняет storage.InitDb
+ALTER TABLE people ADD COLUMN search_name text;
@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"errors"
+	"future_today/internal/cerrors"
 	"future_today/models"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrmRequestManager struct {
@@ -18,48 +22,201 @@ func (orm *OrmRequestManager) Create(person *models.Person) error {
 	return orm.db.Create(person).Error
 }
 
+// withCountries подгружает распределение национальностей по рангу
+func withCountries(db *gorm.DB) *gorm.DB {
+	return db.Order("rank")
+}
+
 func (orm *OrmRequestManager) GetByID(id uint) (*models.Person, error) {
 	var person models.Person
-	err := orm.db.First(&person).Error
+	err := orm.db.Preload("Countries", withCountries).Preload("Provenance").First(&person, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, cerrors.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &person, nil
 }
 
-func (orm *OrmRequestManager) GetAll(
-	name, surname, patronymics, gender, nationality *string,
-	minAge, maxAge *int,
-	limit, offset int) ([]models.Person, error) {
+// GetByIDs персоны по id, отсутствующие пропускаются
+func (orm *OrmRequestManager) GetByIDs(ids []uint) ([]models.Person, error) {
 	var persons []models.Person
-	query := orm.db.Model(&models.Person{}).Where("is_active= ?", true)
-	if name != nil {
-		query = query.Where("name ILIKE ?", "%"+*name+"%")
+	err := orm.db.Preload("Countries", withCountries).Preload("Provenance").Where("id IN ?", ids).Find(&persons).Error
+	return persons, err
+}
+
+// GetAll персоны под фильтром в порядке filter.Sort, при равенстве по id.
+// С filter.Cursor страница начинается после курсора, иначе с filter.Offset
+func (orm *OrmRequestManager) GetAll(filter *models.PersonFilter) ([]models.Person, error) {
+	if err := validateSort(filter.Sort); err != nil {
+		return nil, err
 	}
-	if surname != nil {
-		query = query.Where("surname ILIKE ?", "%"+*surname+"%")
+	query := applySort(applyFilter(orm.db.Model(&models.Person{}), filter), filter.Sort)
+	if filter.Cursor != "" {
+		values, id, err := decodeCursor(filter.Cursor, filter.Sort)
+		if err != nil {
+			return nil, err
+		}
+		where, args := keyset(filter.Sort, values, id)
+		query = query.Where(where, args...)
+	} else {
+		query = query.Offset(filter.Offset)
 	}
-	if minAge != nil {
-		query = query.Where("age >= ?", *minAge)
+	var persons []models.Person
+	err := query.Preload("Countries", withCountries).Preload("Provenance").
+		Limit(filter.Limit).Find(&persons).Error
+	return persons, err
+}
+
+// Each читает персоны курсором базы (sql.Rows) по одной. В sqlite
+// соединение одно, и пока идет обход, остальные запросы ждут
+func (orm *OrmRequestManager) Each(filter *models.PersonFilter, fn func(person *models.Person) error) error {
+	if err := validateSort(filter.Sort); err != nil {
+		return err
 	}
-	if maxAge != nil {
-		query = query.Where("age <= ?", *maxAge)
+	rows, err := applySort(applyFilter(orm.db.Model(&models.Person{}), filter), filter.Sort).Rows()
+	if err != nil {
+		return err
 	}
-	if gender != nil {
-		query = query.Where("gender ILIKE ?", "%"+*gender+"%")
+	defer rows.Close()
+	for rows.Next() {
+		var person models.Person
+		if err := orm.db.ScanRows(rows, &person); err != nil {
+			return err
+		}
+		if err := fn(&person); err != nil {
+			return err
+		}
 	}
-	if nationality != nil {
-		query = query.Where("nationality ILIKE ?", "%"+*nationality+"%")
+	return rows.Err()
+}
+
+func applySort(query *gorm.DB, sort []models.SortField) *gorm.DB {
+	for _, s := range sort {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: sortColumns[s.Field]}, Desc: s.Desc})
 	}
+	return query.Order("id")
+}
 
-	err := query.Limit(limit).Offset(offset).Find(&persons).Error
-	return persons, err
+func (orm *OrmRequestManager) Count(filter *models.PersonFilter) (int64, error) {
+	var count int64
+	err := applyFilter(orm.db.Model(&models.Person{}), filter).Count(&count).Error
+	return count, err
+}
+
+// GetIDs id всех активных персон под фильтром, без пагинации
+func (orm *OrmRequestManager) GetIDs(filter *models.PersonFilter) ([]uint, error) {
+	var ids []uint
+	err := applyFilter(orm.db.Model(&models.Person{}), filter).Order("id").Pluck("id", &ids).Error
+	return ids, err
 }
 
+// GetStaleIDs активные персоны, обогащенные раньше before и без задачи в очереди
+func (orm *OrmRequestManager) GetStaleIDs(before time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	queued := orm.db.Model(&models.EnrichmentJob{}).Select("person_id").
+		Where("status IN ?", []models.JobStatus{models.JobPending, models.JobRunning})
+	err := orm.db.Model(&models.Person{}).
+		Where("is_active = ?", true).
+		Where("enriched_at IS NULL OR enriched_at < ?", before).
+		Where("id NOT IN (?)", queued).
+		Order("enriched_at NULLS FIRST, id").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
+func applyFilter(query *gorm.DB, filter *models.PersonFilter) *gorm.DB {
+	query = query.Where("is_active= ?", true)
+	if filter.Name != nil {
+		query = ilike(query, "name", *filter.Name)
+	}
+	if filter.Surname != nil {
+		query = ilike(query, "surname", *filter.Surname)
+	}
+	if filter.Patronymic != nil {
+		query = ilike(query, "patronymic", *filter.Patronymic)
+	}
+	if filter.MinAge != nil {
+		query = query.Where("age >= ?", *filter.MinAge)
+	}
+	if filter.MaxAge != nil {
+		query = query.Where("age <= ?", *filter.MaxAge)
+	}
+	if filter.Gender != nil {
+		query = ilike(query, "gender", *filter.Gender)
+	}
+	if filter.Nationality != nil {
+		query = ilike(query, "nationality", *filter.Nationality)
+	}
+	if filter.MinGenderProbability != nil {
+		query = query.Where("gender_probability >= ?", *filter.MinGenderProbability)
+	}
+	if filter.MinNationalityProbability != nil {
+		query = query.Where("nationality_probability >= ?", *filter.MinNationalityProbability)
+	}
+	if filter.Expr != nil {
+		query = query.Where(filterClause(filter.Expr, query.Dialector.Name()))
+	}
+	return query
+}
+
+// Update сохраняет поля персоны и их происхождение, страны не трогает
 func (orm *OrmRequestManager) Update(person *models.Person) error {
-	return orm.db.Save(person).Error
+	return orm.db.Transaction(func(tx *gorm.DB) error {
+		return updatePerson(tx, person)
+	})
+}
+
+// UpdateWithCountries как Update, но еще заменяет распределение национальностей
+func (orm *OrmRequestManager) UpdateWithCountries(person *models.Person) error {
+	return orm.db.Transaction(func(tx *gorm.DB) error {
+		if err := updatePerson(tx, person); err != nil {
+			return err
+		}
+		if err := tx.Where("person_id = ?", person.ID).Delete(&models.PersonCountry{}).Error; err != nil {
+			return err
+		}
+		if len(person.Countries) == 0 {
+			return nil
+		}
+		for i := range person.Countries {
+			person.Countries[i].ID = 0
+			person.Countries[i].PersonID = person.ID
+		}
+		return tx.Create(&person.Countries).Error
+	})
+}
+
+func updatePerson(tx *gorm.DB, person *models.Person) error {
+	if err := tx.Omit(clause.Associations).Save(person).Error; err != nil {
+		return err
+	}
+	if len(person.Provenance) == 0 {
+		return nil
+	}
+	for i := range person.Provenance {
+		person.Provenance[i].PersonID = person.ID
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "person_id"}, {Name: "field"}},
+		DoUpdates: clause.AssignmentColumns([]string{"source", "provider", "confidence", "updated_at"}),
+	}).Create(&person.Provenance).Error
+}
+
+func (orm *OrmRequestManager) Transaction(fn func(repo PersonRepository) error) error {
+	return orm.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&OrmRequestManager{db: tx})
+	})
 }
 
 func (orm *OrmRequestManager) Delete(id uint) error {
 	return orm.db.Model(&models.Person{}).Where("id", id).Update("is_active", false).Error
 }
+
+// GetPendingEnrichment активные записи, которые ждут дообогащения
+func (orm *OrmRequestManager) GetPendingEnrichment(limit int) ([]models.Person, error) {
+	var persons []models.Person
+	err := orm.db.Preload("Provenance").Where("is_active = ? AND enrichment_pending = ?", true, true).
+		Order("id").Limit(limit).Find(&persons).Error
+	return persons, err
+}
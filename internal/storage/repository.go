@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"future_today/models"
+	"time"
+)
+
+// PersonRepository хранилище персон. Реализации: OrmRequestManager (gorm)
+// и MemoryPersonRepository (в памяти, для тестов и демо-режима)
+type PersonRepository interface {
+	Create(person *models.Person) error
+	// GetByID возвращает cerrors.ErrNotFound, если персоны нет
+	GetByID(id uint) (*models.Person, error)
+	GetByIDs(ids []uint) ([]models.Person, error)
+	GetAll(filter *models.PersonFilter) ([]models.Person, error)
+	GetIDs(filter *models.PersonFilter) ([]uint, error)
+	// Count число персон под фильтром без учета пагинации
+	Count(filter *models.PersonFilter) (int64, error)
+	// Each вызывает fn для каждой персоны под фильтром в порядке filter.Sort,
+	// не загружая весь список в память. Limit и Offset не учитываются
+	Each(filter *models.PersonFilter, fn func(person *models.Person) error) error
+	// Stats агрегаты по персонам под фильтром с группировкой
+	Stats(filter *models.PersonFilter, query models.PersonStatsQuery) ([]models.PersonStats, error)
+	// Search до limit персон, похожих на запрос, по убыванию релевантности
+	Search(query string, limit int) ([]SearchHit, error)
+	GetStaleIDs(before time.Time, limit int) ([]uint, error)
+	GetPendingEnrichment(limit int) ([]models.Person, error)
+	Update(person *models.Person) error
+	UpdateWithCountries(person *models.Person) error
+	Delete(id uint) error
+	// Transaction выполняет fn в транзакции, ошибка fn откатывает изменения
+	Transaction(fn func(repo PersonRepository) error) error
+}
+
+// JobRepository очередь задач обогащения
+type JobRepository interface {
+	EnqueueWithPerson(person *models.Person) (*models.EnrichmentJob, error)
+	Enqueue(personIDs []uint, force bool) ([]models.EnrichmentJob, error)
+	Claim(lease time.Duration, limit int) ([]models.EnrichmentJob, error)
+	Complete(job *models.EnrichmentJob) error
+	Fail(job *models.EnrichmentJob, cause error, maxAttempts int) error
+	// GetJob возвращает cerrors.ErrNotFound, если задачи нет
+	GetJob(id uint) (*models.EnrichmentJob, error)
+}
+
+var (
+	_ PersonRepository = (*OrmRequestManager)(nil)
+	_ PersonRepository = (*MemoryPersonRepository)(nil)
+	_ JobRepository    = (*JobQueue)(nil)
+	_ JobRepository    = (*MemoryJobQueue)(nil)
+)
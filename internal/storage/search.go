@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"database/sql"
+	"future_today/internal/search"
+	"future_today/models"
+	"reflect"
+	"slices"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// SearchHit персона и ее релевантность запросу поиска
+type SearchHit struct {
+	Person models.Person
+	Score  float64
+}
+
+// searchDocument текст, по которому ищутся персоны. Индексы
+// из миграции 0002_person_search построены по этому же выражению
+const searchDocument = "(coalesce(name, '') || ' ' || coalesce(surname, '') || ' ' || coalesce(patronymic, ''))"
+
+type searchRow struct {
+	ID    uint
+	Score float64
+}
+
+// Search ищет активных персон по имени, фамилии и отчеству с учетом
+// опечаток и транслитерации. В postgres - полнотекстовый поиск и pg_trgm,
+// транслитерированный запрос сравнивается с search_name. В sqlite
+// похожесть считается в приложении перебором
+func (orm *OrmRequestManager) Search(query string, limit int) ([]SearchHit, error) {
+	if orm.db.Dialector.Name() != DriverPostgres {
+		return orm.searchScan(query, limit)
+	}
+	var rows []searchRow
+	err := orm.db.Raw(`SELECT id,
+			ts_rank(to_tsvector('simple', `+searchDocument+`), plainto_tsquery('simple', @q))
+				+ greatest(word_similarity(@q, `+searchDocument+`), word_similarity(@lq, search_name)) AS score
+		FROM people
+		WHERE is_active AND deleted_at IS NULL
+			AND (to_tsvector('simple', `+searchDocument+`) @@ plainto_tsquery('simple', @q)
+				OR @q <% `+searchDocument+`
+				OR @lq <% search_name)
+		ORDER BY score DESC, id
+		LIMIT @limit`, sql.Named("q", query), sql.Named("lq", search.Latin(query)), sql.Named("limit", limit)).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return orm.searchHits(rows)
+}
+
+func (orm *OrmRequestManager) searchScan(query string, limit int) ([]SearchHit, error) {
+	var rows []searchRow
+	var batch []models.Person
+	err := orm.db.Select("id", "name", "surname", "patronymic").Where("is_active = ?", true).
+		FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+			for _, p := range batch {
+				if score := search.Score(query, p.Name, p.Surname, p.Patronymic); score > 0 {
+					rows = append(rows, searchRow{ID: p.ID, Score: score})
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	return orm.searchHits(topRows(rows, limit))
+}
+
+// searchHits загружает персоны найденных строк в порядке релевантности
+func (orm *OrmRequestManager) searchHits(rows []searchRow) ([]SearchHit, error) {
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	persons, err := orm.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, 0, len(rows))
+	for _, row := range rows {
+		i := slices.IndexFunc(persons, func(p models.Person) bool { return p.ID == row.ID })
+		if i >= 0 {
+			hits = append(hits, SearchHit{Person: persons[i], Score: row.Score})
+		}
+	}
+	return hits, nil
+}
+
+// topRows limit самых релевантных, при равенстве по id
+func topRows(rows []searchRow, limit int) []searchRow {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Score != rows[j].Score {
+			return rows[i].Score > rows[j].Score
+		}
+		return rows[i].ID < rows[j].ID
+	})
+	return rows[:min(limit, len(rows))]
+}
+
+func (r *MemoryPersonRepository) Search(query string, limit int) ([]SearchHit, error) {
+	defer r.db.lock(r.tx)()
+	var rows []searchRow
+	for _, p := range r.db.persons {
+		if !p.IsActive {
+			continue
+		}
+		if score := search.Score(query, p.Name, p.Surname, p.Patronymic); score > 0 {
+			rows = append(rows, searchRow{ID: p.ID, Score: score})
+		}
+	}
+	rows = topRows(rows, limit)
+	hits := make([]SearchHit, len(rows))
+	for i, row := range rows {
+		hits[i] = SearchHit{Person: *copyPerson(r.db.persons[row.ID]), Score: row.Score}
+	}
+	return hits, nil
+}
+
+// registerSearchName заполняет search_name при каждом создании и
+// сохранении персоны, в том числе пачками и внутри транзакций
+func registerSearchName(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("people:search_name", setSearchName); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("people:search_name", setSearchName)
+}
+
+func setSearchName(tx *gorm.DB) {
+	if tx.Statement.Schema == nil || tx.Statement.Schema.Table != "people" {
+		return
+	}
+	set := func(v reflect.Value) {
+		if v.Kind() != reflect.Pointer {
+			if !v.CanAddr() {
+				return
+			}
+			v = v.Addr()
+		}
+		if p, ok := v.Interface().(*models.Person); ok && p != nil {
+			p.SearchName = search.Latin(p.Name, p.Surname, p.Patronymic)
+		}
+	}
+	switch rv := tx.Statement.ReflectValue; rv.Kind() {
+	case reflect.Struct:
+		set(rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(rv.Index(i))
+		}
+	}
+}
+
+// backfillSearchNames заполняет search_name персон, сохраненных до миграции
+// 0004_person_search_name. Новые персоны получают его в registerSearchName
+func backfillSearchNames(db *gorm.DB) error {
+	var batch []models.Person
+	return db.Select("id", "name", "surname", "patronymic").Where("search_name IS NULL").
+		FindInBatches(&batch, 1000, func(tx *gorm.DB, _ int) error {
+			for _, p := range batch {
+				err := tx.Model(&models.Person{}).Where("id = ?", p.ID).
+					UpdateColumn("search_name", search.Latin(p.Name, p.Surname, p.Patronymic)).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
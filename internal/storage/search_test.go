@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"future_today/models"
+	"testing"
+)
+
+// TestSearchNameOnWrite search_name заполняется на всех путях записи персоны
+func TestSearchNameOnWrite(t *testing.T) {
+	db := newSQLiteDB(t)
+	repo, queue, imports := NewOrmRequestManager(db), NewJobQueue(db), NewImportStore(db)
+
+	created := &models.Person{Name: "Дмитрий", Surname: "Ушаков", IsActive: true}
+	if err := repo.Create(created); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	queued := &models.Person{Name: "Юлия", Surname: "Щукина", IsActive: true}
+	if _, err := queue.EnqueueWithPerson(queued); err != nil {
+		t.Fatalf("EnqueueWithPerson: %v", err)
+	}
+	job := &models.ImportJob{Status: models.ImportRunning}
+	if err := imports.CreateImport(job); err != nil {
+		t.Fatalf("CreateImport: %v", err)
+	}
+	imported := &models.Person{Name: "Ёлкин", Surname: "Пётр", IsActive: true}
+	if err := imports.ImportBatch(job.ID, []ImportItem{{Person: imported}}); err != nil {
+		t.Fatalf("ImportBatch: %v", err)
+	}
+	updated := &models.Person{Name: "Anna", Surname: "Ivanova", IsActive: true}
+	if err := repo.Create(updated); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	updated.Surname, updated.Patronymic = "Хабибуллина", "Сергеевна"
+	if err := repo.Update(updated); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	tests := []struct {
+		id   uint
+		want string
+	}{
+		{created.ID, "dmitrii ushakov"},
+		{queued.ID, "iuliia shchukina"},
+		{imported.ID, "elkin petr"},
+		{updated.ID, "anna khabibullina sergeevna"},
+	}
+	for _, tt := range tests {
+		var got string
+		if err := db.Model(&models.Person{}).Where("id = ?", tt.id).Pluck("search_name", &got).Error; err != nil {
+			t.Fatalf("Pluck: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("person %d: search_name = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+
+	// запрос в латинице находит персону, записанную кириллицей
+	hits, err := repo.Search("ushakov", 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Person.ID != created.ID {
+		t.Errorf("hits = %+v, want Ushakov", hits)
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+	"future_today/internal/cerrors"
+	"future_today/models"
+	"slices"
+	"strings"
+)
+
+// sortColumns поля, по которым можно сортировать список персон
+var sortColumns = map[string]string{
+	"id":                      "id",
+	"name":                    "name",
+	"surname":                 "surname",
+	"patronymic":              "patronymic",
+	"age":                     "age",
+	"gender":                  "gender",
+	"nationality":             "nationality",
+	"gender_probability":      "gender_probability",
+	"nationality_probability": "nationality_probability",
+	"created_at":              "created_at",
+	"updated_at":              "updated_at",
+	"enriched_at":             "enriched_at",
+}
+
+// validateSort проверяет поля по белому списку
+func validateSort(sort []models.SortField) error {
+	for _, s := range sort {
+		if _, ok := sortColumns[s.Field]; !ok {
+			return fmt.Errorf("%w: %q, allowed: %s", cerrors.ErrInvalidSort, s.Field, strings.Join(SortFields(), ", "))
+		}
+	}
+	return nil
+}
+
+// SortFields имена полей, по которым можно сортировать
+func SortFields() []string {
+	fields := make([]string, 0, len(sortColumns))
+	for field := range sortColumns {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	return fields
+}
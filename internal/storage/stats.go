@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"future_today/internal/cerrors"
+	"future_today/models"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+// validateStats проверяет группировку: известные поля без повторов
+// и положительная ширина корзины возраста
+func validateStats(query models.PersonStatsQuery) error {
+	for i, field := range query.GroupBy {
+		switch field {
+		case models.GroupByGender, models.GroupByNationality, models.GroupByAge:
+		default:
+			return fmt.Errorf("%w: %q, allowed: gender, nationality, age", cerrors.ErrInvalidGroupBy, field)
+		}
+		if slices.Contains(query.GroupBy[:i], field) {
+			return fmt.Errorf("%w: %q repeated", cerrors.ErrInvalidGroupBy, field)
+		}
+	}
+	if slices.Contains(query.GroupBy, models.GroupByAge) && query.AgeBucket < 1 {
+		return fmt.Errorf("%w: age bucket must be positive", cerrors.ErrInvalidGroupBy)
+	}
+	return nil
+}
+
+// Stats агрегаты по персонам под фильтром, сгруппированные по query.
+// Группы по убыванию числа персон
+func (orm *OrmRequestManager) Stats(filter *models.PersonFilter, query models.PersonStatsQuery) ([]models.PersonStats, error) {
+	if err := validateStats(query); err != nil {
+		return nil, err
+	}
+	var columns []string
+	var groups []clause.Column
+	for i, field := range query.GroupBy {
+		switch field {
+		case models.GroupByGender:
+			columns = append(columns, "NULLIF(gender, '') AS gender")
+		case models.GroupByNationality:
+			columns = append(columns, "NULLIF(nationality, '') AS nationality")
+		case models.GroupByAge:
+			// целочисленное деление в обеих базах, ширина - проверенное число
+			columns = append(columns, fmt.Sprintf("(NULLIF(age, 0) / %d) * %d AS age_from", query.AgeBucket, query.AgeBucket))
+		}
+		groups = append(groups, clause.Column{Name: fmt.Sprint(i + 1), Raw: true})
+	}
+	columns = append(columns,
+		"COUNT(*) AS count",
+		"AVG(NULLIF(age, 0)) AS avg_age",
+		"MIN(NULLIF(age, 0)) AS min_age",
+		"MAX(NULLIF(age, 0)) AS max_age",
+	)
+
+	q := applyFilter(orm.db.Model(&models.Person{}), filter).Select(strings.Join(columns, ", "))
+	if len(groups) > 0 {
+		// группировка по номерам колонок: алиасы совпадают с именами колонок
+		q = q.Clauses(clause.GroupBy{Columns: groups}).Order(fmt.Sprintf("%d DESC", len(groups)+1))
+		for _, g := range groups {
+			q = q.Order(g.Name + " NULLS LAST")
+		}
+	}
+	var stats []models.PersonStats
+	err := q.Scan(&stats).Error
+	return stats, err
+}
+
+func (r *MemoryPersonRepository) Stats(filter *models.PersonFilter, query models.PersonStatsQuery) ([]models.PersonStats, error) {
+	if err := validateStats(query); err != nil {
+		return nil, err
+	}
+	defer r.db.lock(r.tx)()
+
+	type acc struct {
+		stats  models.PersonStats
+		ageSum int
+		aged   int
+	}
+	byKey := map[string]*acc{}
+	var order []*acc
+	for _, p := range r.db.filtered(filter) {
+		var key models.PersonStats
+		for _, field := range query.GroupBy {
+			switch field {
+			case models.GroupByGender:
+				key.Gender = nullIfEmpty(p.Gender)
+			case models.GroupByNationality:
+				key.Nationality = nullIfEmpty(p.Nationality)
+			case models.GroupByAge:
+				if p.Age != 0 {
+					from := p.Age / query.AgeBucket * query.AgeBucket
+					key.AgeFrom = &from
+				}
+			}
+		}
+		k := fmt.Sprint(deref(key.Gender), "\x00", deref(key.Nationality), "\x00", deref(key.AgeFrom))
+		a, ok := byKey[k]
+		if !ok {
+			a = &acc{stats: key}
+			byKey[k] = a
+			order = append(order, a)
+		}
+		a.stats.Count++
+		if p.Age != 0 {
+			a.ageSum += p.Age
+			a.aged++
+			a.stats.MinAge = ptr(min(p.Age, derefOr(a.stats.MinAge, p.Age)))
+			a.stats.MaxAge = ptr(max(p.Age, derefOr(a.stats.MaxAge, p.Age)))
+		}
+	}
+
+	stats := make([]models.PersonStats, len(order))
+	for i, a := range order {
+		if a.aged > 0 {
+			a.stats.AvgAge = ptr(float64(a.ageSum) / float64(a.aged))
+		}
+		stats[i] = a.stats
+	}
+	if len(query.GroupBy) == 0 && len(stats) == 0 {
+		// без группировки SQL всегда возвращает одну строку
+		stats = append(stats, models.PersonStats{})
+	}
+	// как ORDER BY count DESC, колонки группировки в порядке GroupBy NULLS LAST
+	slices.SortStableFunc(stats, func(a, b models.PersonStats) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		for _, field := range query.GroupBy {
+			var c int
+			switch field {
+			case models.GroupByGender:
+				c = compareNullable(a.Gender, b.Gender)
+			case models.GroupByNationality:
+				c = compareNullable(a.Nationality, b.Nationality)
+			case models.GroupByAge:
+				c = compareNullable(a.AgeFrom, b.AgeFrom)
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return stats, nil
+}
+
+// compareNullable сравнивает значения, nil больше любого значения
+func compareNullable[T cmp.Ordered](a, b *T) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return cmp.Compare(*a, *b)
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func deref[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+func derefOr[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
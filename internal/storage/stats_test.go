@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"fmt"
+	"future_today/models"
+	"strings"
+	"testing"
+)
+
+// formatStats строки статистики в сравнимом виде, среднее округлено
+func formatStats(stats []models.PersonStats) string {
+	lines := make([]string, len(stats))
+	for i, s := range stats {
+		avg := "-"
+		if s.AvgAge != nil {
+			avg = fmt.Sprintf("%.2f", *s.AvgAge)
+		}
+		lines[i] = fmt.Sprintf("%v/%v/%v count=%d avg=%s min=%v max=%v",
+			deref(s.Gender), deref(s.Nationality), deref(s.AgeFrom), s.Count, avg, deref(s.MinAge), deref(s.MaxAge))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TestStatsMatchAcrossRepositories группы и их порядок одинаковы в памяти
+// и в sqlite, в том числе при равном числе персон
+func TestStatsMatchAcrossRepositories(t *testing.T) {
+	persons := []models.Person{
+		{Name: "a", Gender: "male", Nationality: "RU", Age: 31},
+		{Name: "b", Gender: "female", Nationality: "RU", Age: 45},
+		{Name: "c", Gender: "male", Nationality: "UA", Age: 38},
+		{Name: "d", Gender: "female", Nationality: "UA", Age: 22},
+		{Name: "e", Gender: "male", Nationality: "BY"},
+		{Name: "f", Gender: "female", Nationality: "BY", Age: 67},
+		{Name: "g", Nationality: "RU", Age: 35},
+		{Name: "h", Gender: "male", Age: 29},
+		{Name: "i", Gender: "female", Nationality: "KZ", Age: 41},
+	}
+	memory, _, _ := NewMemoryStorage()
+	sqlite := newSQLiteRepo(t)
+	for _, p := range persons {
+		p.IsActive = true
+		for _, repo := range []PersonRepository{memory, sqlite} {
+			p := p
+			if err := repo.Create(&p); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+		}
+	}
+
+	queries := []models.PersonStatsQuery{
+		{},
+		{GroupBy: []string{models.GroupByGender}},
+		{GroupBy: []string{models.GroupByNationality, models.GroupByGender}},
+		{GroupBy: []string{models.GroupByGender, models.GroupByNationality}},
+		{GroupBy: []string{models.GroupByAge}, AgeBucket: 10},
+		{GroupBy: []string{models.GroupByAge, models.GroupByGender}, AgeBucket: 20},
+	}
+	minAge := 30
+	filters := []*models.PersonFilter{{}, {MinAge: &minAge}}
+	for _, filter := range filters {
+		for _, query := range queries {
+			t.Run(fmt.Sprintf("%v/min_age=%v", query.GroupBy, deref(filter.MinAge)), func(t *testing.T) {
+				want, err := sqlite.Stats(filter, query)
+				if err != nil {
+					t.Fatalf("sqlite Stats: %v", err)
+				}
+				got, err := memory.Stats(filter, query)
+				if err != nil {
+					t.Fatalf("memory Stats: %v", err)
+				}
+				if g, w := formatStats(got), formatStats(want); g != w {
+					t.Errorf("memory:\n%s\nsqlite:\n%s", g, w)
+				}
+			})
+		}
+	}
+}
+
+func TestStatsInvalidGroupBy(t *testing.T) {
+	memory, _, _ := NewMemoryStorage()
+	for _, query := range []models.PersonStatsQuery{
+		{GroupBy: []string{"name"}},
+		{GroupBy: []string{models.GroupByGender, models.GroupByGender}},
+		{GroupBy: []string{models.GroupByAge}},
+	} {
+		if _, err := memory.Stats(&models.PersonFilter{}, query); err == nil {
+			t.Errorf("Stats(%v) without error", query)
+		}
+	}
+}
@@ -4,27 +4,84 @@ import (
 	"fmt"
 	"future_today/internal/cerrors"
 	"future_today/internal/config"
+	"future_today/internal/storage/migrations"
 	"future_today/models"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func InitDb(cfg *config.Config) (*gorm.DB, error) {
-	dbConnString := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-		cfg.DbHost, cfg.DbUser, cfg.DbPass, cfg.DbName, cfg.DbPort,
-	)
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
 
-	db, err := gorm.Open(postgres.Open(dbConnString), &gorm.Config{})
+// InitDb подключается к базе и приводит схему к актуальной: по умолчанию
+// SQL-миграциями, с DB_AUTO_MIGRATE - через AutoMigrate (для разработки)
+func InitDb(cfg *config.Config) (*gorm.DB, error) {
+	db, err := OpenDb(cfg)
 	if err != nil {
-		return nil, cerrors.ErrDbConnect
+		return nil, err
 	}
 
-	err = db.AutoMigrate(&models.Person{})
-	if err != nil {
-		return nil, cerrors.ErrMigration
+	if cfg.DbAutoMigrate {
+		err = db.AutoMigrate(&models.Person{}, &models.PersonCountry{}, &models.FieldProvenance{}, &models.NameEnrichment{}, &models.EnrichmentJob{},
+			&models.ImportJob{}, &models.ImportRow{})
+		if err != nil {
+			return nil, cerrors.ErrMigration
+		}
+	} else {
+		migrator, err := migrations.New(db)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", cerrors.ErrMigration, err)
+		}
+		if _, err := migrator.Up(); err != nil {
+			return nil, fmt.Errorf("%w: %v", cerrors.ErrMigration, err)
+		}
 	}
+	if err := backfillSearchNames(db); err != nil {
+		return nil, fmt.Errorf("%w: %v", cerrors.ErrMigration, err)
+	}
+	return db, nil
+}
 
+// OpenDb только подключается к базе, схему не трогает
+func OpenDb(cfg *config.Config) (*gorm.DB, error) {
+	dialector, err := openDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
 
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, cerrors.ErrDbConnect
+	}
+	if err := registerSearchName(db); err != nil {
+		return nil, fmt.Errorf("%w: %v", cerrors.ErrDbConnect, err)
+	}
+	if cfg.DbDriver == DriverSQLite {
+		// sqlite пишет в один поток, лишние соединения ловят database is locked
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, cerrors.ErrDbConnect
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
 	return db, nil
 }
+
+func openDialector(cfg *config.Config) (gorm.Dialector, error) {
+	switch cfg.DbDriver {
+	case DriverPostgres:
+		dbConnString := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
+			cfg.DbHost, cfg.DbUser, cfg.DbPass, cfg.DbName, cfg.DbPort,
+		)
+		return postgres.Open(dbConnString), nil
+	case DriverSQLite:
+		registerSQLiteFunctions()
+		return sqlite.Open(cfg.DbPath + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown DB_DRIVER %q", cerrors.ErrInvalidConfig, cfg.DbDriver)
+	}
+}
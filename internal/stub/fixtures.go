@@ -0,0 +1,126 @@
+package stub
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Имена API заглушки, они же префиксы путей
+const (
+	APIAgify       = "agify"
+	APIGenderize   = "genderize"
+	APINationalize = "nationalize"
+	// AnyAPI сбой для всех трех API
+	AnyAPI = "*"
+)
+
+// Fixtures ответы заглушки. Имена без фикстуры получают пустой ответ,
+// как у настоящих API для незнакомого имени
+type Fixtures struct {
+	// RateLimit сколько запросов принимает каждый API, 0 - без лимита
+	RateLimit int `json:"rate_limit"`
+	// RateLimitReset через сколько секунд после первого запроса лимит сбрасывается
+	RateLimitReset int                     `json:"rate_limit_reset"`
+	Names          map[string]*NameFixture `json:"names"`
+}
+
+type NameFixture struct {
+	Stats
+	Countries []Country `json:"countries"`
+	// Localized статистика по стране для запросов с country_id
+	Localized map[string]Stats `json:"localized"`
+	// Faults сбои по API, ключ - имя API или "*"
+	Faults map[string]Fault `json:"faults"`
+}
+
+type Stats struct {
+	Age               *int    `json:"age"`
+	AgeCount          int     `json:"age_count"`
+	Gender            *string `json:"gender"`
+	GenderProbability float64 `json:"gender_probability"`
+	GenderCount       int     `json:"gender_count"`
+}
+
+type Country struct {
+	CountryID   string  `json:"country_id"`
+	Probability float64 `json:"probability"`
+}
+
+// Fault как испортить ответ
+type Fault struct {
+	// Status код ответа вместо 200, 429 отдается с заголовками лимита
+	Status int `json:"status"`
+	// Delay задержка перед ответом, например "3s"
+	Delay Duration `json:"delay"`
+	// Malformed тело ответа не является JSON
+	Malformed bool `json:"malformed"`
+	// Times сколько первых запросов с этим именем портить, 0 - все
+	Times int `json:"times"`
+}
+
+// Duration time.Duration, которая читается из строки "1.5s"
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadFixtures читает фикстуры из JSON-файла
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fixtures: %w", err)
+	}
+	var fx Fixtures
+	if err := json.Unmarshal(data, &fx); err != nil {
+		return nil, fmt.Errorf("error parsing fixtures %s: %w", path, err)
+	}
+	names := make(map[string]*NameFixture, len(fx.Names))
+	for name, f := range fx.Names {
+		names[key(name)] = f
+	}
+	fx.Names = names
+	return &fx, nil
+}
+
+func (fx *Fixtures) name(name string) *NameFixture {
+	if f, ok := fx.Names[key(name)]; ok {
+		return f
+	}
+	return &NameFixture{}
+}
+
+func (f *NameFixture) stats(countryID string) Stats {
+	if countryID == "" {
+		return f.Stats
+	}
+	if s, ok := f.Localized[strings.ToUpper(countryID)]; ok {
+		return s
+	}
+	// настоящие API для страны без данных отдают пустой ответ
+	return Stats{}
+}
+
+func (f *NameFixture) fault(api string) (Fault, bool) {
+	if fault, ok := f.Faults[api]; ok {
+		return fault, true
+	}
+	fault, ok := f.Faults[AnyAPI]
+	return fault, ok
+}
+
+func key(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
@@ -0,0 +1,230 @@
+package stub
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server заглушка agify, genderize и nationalize для разработки и тестов.
+// Каждый API живет под своим префиксом: /agify/, /genderize/, /nationalize/.
+// Поддерживает одиночные (?name=) и пакетные (?name[]=) запросы и country_id
+type Server struct {
+	fx  *Fixtures
+	mux *http.ServeMux
+
+	mu       sync.Mutex
+	used     map[string]int
+	windows  map[string]time.Time
+	requests map[string]int
+	faulted  map[string]int
+	now      func() time.Time
+}
+
+func NewServer(fx *Fixtures) *Server {
+	s := &Server{fx: fx, mux: http.NewServeMux(), used: map[string]int{}, windows: map[string]time.Time{},
+		requests: map[string]int{}, faulted: map[string]int{}, now: time.Now}
+	s.mux.HandleFunc("/"+APIAgify+"/", s.handle(APIAgify, agify))
+	s.mux.HandleFunc("/"+APIGenderize+"/", s.handle(APIGenderize, genderize))
+	s.mux.HandleFunc("/"+APINationalize+"/", s.handle(APINationalize, nationalize))
+	return s
+}
+
+// TestServer заглушка на свободном порту, Stub - для проверки запросов
+type TestServer struct {
+	*httptest.Server
+	Stub *Server
+}
+
+// NewTestServer запускает заглушку на свободном порту, для тестов
+func NewTestServer(fx *Fixtures) *TestServer {
+	s := NewServer(fx)
+	return &TestServer{Server: httptest.NewServer(s), Stub: s}
+}
+
+// Requests сколько запросов получил API, включая испорченные
+func (s *Server) Requests(api string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[api]
+}
+
+// URLs адреса трех API на заглушке с адресом base, для config.Config
+func URLs(base string) (agifyURL, genderizeURL, nationalizeURL string) {
+	base = strings.TrimRight(base, "/")
+	return base + "/" + APIAgify, base + "/" + APIGenderize, base + "/" + APINationalize
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// answer тело ответа одного API на одно имя
+type answer func(name, countryID string, f *NameFixture) map[string]any
+
+func (s *Server) handle(api string, answerFor answer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		names, batch := query["name[]"], true
+		if len(names) == 0 {
+			names, batch = query["name"], false
+		}
+		s.mu.Lock()
+		s.requests[api]++
+		s.mu.Unlock()
+		if len(names) == 0 {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Missing 'name' parameter"})
+			return
+		}
+
+		// сбой любого имени в пакете портит весь ответ, как у настоящих API
+		for _, name := range names {
+			if fault, ok := s.fault(api, name); ok {
+				if s.fail(w, r, fault) {
+					return
+				}
+			}
+		}
+
+		remaining, resetIn, ok := s.take(api, len(names))
+		s.limitHeaders(w, remaining, resetIn)
+		if !ok {
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Request limit reached"})
+			return
+		}
+
+		countryID := query.Get("country_id")
+		answers := make([]map[string]any, len(names))
+		for i, name := range names {
+			answers[i] = answerFor(name, countryID, s.fx.name(name))
+		}
+		if batch {
+			writeJSON(w, http.StatusOK, answers)
+			return
+		}
+		writeJSON(w, http.StatusOK, answers[0])
+	}
+}
+
+// fault сбой имени в API с учетом Fault.Times
+func (s *Server) fault(api, name string) (Fault, bool) {
+	fault, ok := s.fx.name(name).fault(api)
+	if !ok || fault.Times <= 0 {
+		return fault, ok
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := api + "/" + key(name)
+	if s.faulted[k] >= fault.Times {
+		return Fault{}, false
+	}
+	s.faulted[k]++
+	return fault, true
+}
+
+// fail применяет сбой. true, если ответ уже отправлен
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, fault Fault) bool {
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(time.Duration(fault.Delay)):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+	switch {
+	case fault.Status == http.StatusTooManyRequests:
+		s.limitHeaders(w, 0, s.period())
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "Request limit reached"})
+		return true
+	case fault.Status != 0 && fault.Status != http.StatusOK:
+		writeJSON(w, fault.Status, map[string]string{"error": http.StatusText(fault.Status)})
+		return true
+	case fault.Malformed:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"count": 1, "name": `))
+		return true
+	}
+	return false
+}
+
+// period окно лимита, по умолчанию сутки, как у agify
+func (s *Server) period() time.Duration {
+	reset := s.fx.RateLimitReset
+	if reset <= 0 {
+		reset = 24 * 60 * 60
+	}
+	return time.Duration(reset) * time.Second
+}
+
+// take списывает cost запросов из лимита API. Лимит обнуляется через
+// period после первого запроса окна. Возвращает остаток и время до сброса
+func (s *Server) take(api string, cost int) (int, time.Duration, bool) {
+	if s.fx.RateLimit <= 0 {
+		return -1, 0, true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if start, ok := s.windows[api]; !ok || now.Sub(start) >= s.period() {
+		s.windows[api] = now
+		s.used[api] = 0
+	}
+	resetIn := s.period() - now.Sub(s.windows[api])
+	if s.used[api]+cost > s.fx.RateLimit {
+		return 0, resetIn, false
+	}
+	s.used[api] += cost
+	return s.fx.RateLimit - s.used[api], resetIn, true
+}
+
+// limitHeaders заголовки лимита как у agify, remaining < 0 - без лимита
+func (s *Server) limitHeaders(w http.ResponseWriter, remaining int, resetIn time.Duration) {
+	if remaining < 0 {
+		return
+	}
+	w.Header().Set("X-Rate-Limit-Limit", strconv.Itoa(s.fx.RateLimit))
+	w.Header().Set("X-Rate-Limit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-Rate-Limit-Reset", strconv.Itoa(int(math.Ceil(resetIn.Seconds()))))
+}
+
+func agify(name, countryID string, f *NameFixture) map[string]any {
+	stats := f.stats(countryID)
+	out := map[string]any{"name": name, "age": stats.Age, "count": stats.AgeCount}
+	if countryID != "" {
+		out["country_id"] = countryID
+	}
+	return out
+}
+
+func genderize(name, countryID string, f *NameFixture) map[string]any {
+	stats := f.stats(countryID)
+	out := map[string]any{"name": name, "gender": stats.Gender, "probability": stats.GenderProbability, "count": stats.GenderCount}
+	if countryID != "" {
+		out["country_id"] = countryID
+	}
+	return out
+}
+
+func nationalize(name, _ string, f *NameFixture) map[string]any {
+	countries := f.Countries
+	if countries == nil {
+		countries = []Country{}
+	}
+	count := f.AgeCount
+	if count == 0 {
+		count = f.GenderCount
+	}
+	return map[string]any{"name": name, "country": countries, "count": count}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
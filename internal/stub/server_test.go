@@ -0,0 +1,50 @@
+package stub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitWindow(t *testing.T) {
+	s := NewServer(&Fixtures{RateLimit: 2, RateLimitReset: 5})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	tests := []struct {
+		after     time.Duration
+		status    int
+		remaining string
+		reset     string
+	}{
+		{0, http.StatusOK, "1", "5"},
+		{2 * time.Second, http.StatusOK, "0", "3"},
+		{3 * time.Second, http.StatusTooManyRequests, "0", "2"},
+		// окно кончилось, лимит сброшен
+		{5 * time.Second, http.StatusOK, "1", "5"},
+		{5500 * time.Millisecond, http.StatusOK, "0", "5"},
+		{6 * time.Second, http.StatusTooManyRequests, "0", "4"},
+	}
+	start := now
+	for _, tt := range tests {
+		now = start.Add(tt.after)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/agify/?name=olga", nil))
+		if rec.Code != tt.status {
+			t.Errorf("after %s: status %d, want %d", tt.after, rec.Code, tt.status)
+		}
+		if got := rec.Header().Get("X-Rate-Limit-Remaining"); got != tt.remaining {
+			t.Errorf("after %s: remaining %s, want %s", tt.after, got, tt.remaining)
+		}
+		if got := rec.Header().Get("X-Rate-Limit-Reset"); got != tt.reset {
+			t.Errorf("after %s: reset %s, want %s", tt.after, got, tt.reset)
+		}
+	}
+	// у каждого API свое окно
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/genderize/?name=olga", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("genderize: status %d, want 200", rec.Code)
+	}
+}
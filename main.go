@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"future_today/internal/addition"
 	"future_today/internal/config"
 	"future_today/internal/controllers"
 	"future_today/internal/storage"
+	"future_today/internal/storage/migrations"
+	"future_today/internal/stub"
 	person_service "future_today/services"
 	"future_today/utils"
 	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
 
 	_ "future_today/docs"
 
@@ -34,6 +43,19 @@ import (
 // @host localhost:8080
 // @BasePath /
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "stub":
+			runStub(os.Args[2:])
+			return
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		}
+	}
+	// memory - персоны и очередь в памяти процесса, без базы (демо-режим)
+	storageMode := flag.String("storage", "db", "storage backend: db or memory")
+	flag.Parse()
 
 	//config init
 	cfg, err := config.GetConfig()
@@ -42,31 +64,78 @@ func main() {
 	}
 	//logger init
 	logger := utils.NewLogger()
-	//db init
-	db, err := storage.InitDb(cfg)
-	if err != nil {
-		log.Fatal("Can't connect to db")
+	//storage init
+	var repo storage.PersonRepository
+	var jobQueue storage.JobRepository
+	var imports storage.ImportRepository
+	var cacheStore addition.CacheStore
+	switch *storageMode {
+	case "memory":
+		repo, jobQueue, imports = storage.NewMemoryStorage()
+		logger.Warn("Using in-memory storage, data will be lost on restart")
+	case "db":
+		db, err := storage.InitDb(cfg)
+		if err != nil {
+			log.Fatalf("Can't connect to db: %v", err)
+		}
+		repo = storage.NewOrmRequestManager(db)
+		jobQueue = storage.NewJobQueue(db)
+		imports = storage.NewImportStore(db)
+		cacheStore = storage.NewEnrichmentCache(db)
+	default:
+		log.Fatalf("Unknown storage %q", *storageMode)
 	}
-	//reqs
-	ormReq := storage.NewOrmRequestManager(db)
+	//cache
+	cache := addition.NewCache(cfg.CacheSize, cfg.CacheTTL, cacheStore)
 	//services
-	add := addition.NewAddition(cfg)
-	personService := person_service.NewPersonService(add, ormReq)
+	add, err := addition.NewAddition(cfg, cache)
+	if err != nil {
+		log.Fatalf("Can't init enrichment providers: %v", err)
+	}
+	personService := person_service.NewPersonService(add, repo, jobQueue)
+	importService := person_service.NewImportService(imports, cfg.ImportBatchSize)
+	reconciler := person_service.NewReconciler(personService, repo, logger, cfg.ReconcileInterval, cfg.ReconcileBatch)
+	go reconciler.Run(context.Background())
+	refresher := person_service.NewRefresher(repo, jobQueue, logger, cfg.RefreshInterval, cfg.RefreshMaxAge, cfg.RefreshBatch)
+	go refresher.Run(context.Background())
+	workers := person_service.NewEnrichmentWorkers(personService, jobQueue, logger,
+		cfg.JobWorkers, cfg.JobBatch, cfg.JobPollInterval, cfg.JobLease, cfg.JobMaxAttempts)
+	go workers.Run(context.Background())
 	//controllers
 	personCtrl := controllers.NewPersonController(personService, logger)
+	enrichCtrl := controllers.NewEnrichmentController(add, cache, logger)
+	jobCtrl := controllers.NewJobController(personService, logger)
+	importCtrl := controllers.NewImportController(importService, logger)
 	//router
 	router := gin.Default()
 
 	api := router.Group("/personApi/v1")
 	{
 		api.GET("/persons", personCtrl.GetAllPersons)
+		api.GET("/persons/search", personCtrl.SearchPersons)
+		api.GET("/persons/stats", personCtrl.GetPersonStats)
+		api.GET("/persons/export", personCtrl.ExportPersons)
+		api.POST("/persons/import", importCtrl.Import)
 		api.GET("/persons/:id", personCtrl.GetPerson)
 		api.POST("/persons", personCtrl.CreatePerson)
 		api.PUT("/persons/:id", personCtrl.UpdatePerson)
 		api.DELETE("/persons/:id", personCtrl.DeletePerson)
+		api.POST("/persons/:id/enrich", personCtrl.EnrichPerson)
+		api.POST("/enrich", personCtrl.EnrichPersons)
+
+		api.GET("/jobs/:id", jobCtrl.GetJob)
+
+		api.GET("/imports/:id", importCtrl.GetImport)
+		api.GET("/imports/:id/report", importCtrl.GetImportReport)
+
+		api.GET("/enrichment/preview", enrichCtrl.Preview)
+		api.DELETE("/enrichment/cache/:provider", enrichCtrl.InvalidateCache)
 	}
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	router.GET("/diagnostics/breakers", enrichCtrl.GetBreakers)
+	router.POST("/admin/enrichment/dataset/reload", enrichCtrl.ReloadDataset)
+
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
@@ -76,3 +145,82 @@ func main() {
 		logger.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// runStub запускает заглушку agify/genderize/nationalize: go-app stub -addr :8081
+func runStub(args []string) {
+	fs := flag.NewFlagSet("stub", flag.ExitOnError)
+	addr := fs.String("addr", ":8081", "listen address")
+	fixtures := fs.String("fixtures", "data/stub_fixtures.json", "fixtures file")
+	_ = fs.Parse(args)
+
+	fx, err := stub.LoadFixtures(*fixtures)
+	if err != nil {
+		log.Fatalf("Can't load stub fixtures: %v", err)
+	}
+	agifyURL, genderizeURL, nationalizeURL := stub.URLs("http://localhost" + *addr)
+	log.Printf("Stub listening on %s: %s %s %s", *addr, agifyURL, genderizeURL, nationalizeURL)
+	if err := http.ListenAndServe(*addr, stub.NewServer(fx)); err != nil {
+		log.Fatalf("Stub failed: %v", err)
+	}
+}
+
+// runMigrate управляет схемой базы: go-app migrate up|down [n]|status
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: migrate up|down [n]|status")
+	}
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Fatalf("Can't get config: %v", err)
+	}
+	db, err := storage.OpenDb(cfg)
+	if err != nil {
+		log.Fatalf("Can't connect to db: %v", err)
+	}
+	migrator, err := migrations.New(db)
+	if err != nil {
+		log.Fatalf("Can't load migrations: %v", err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, mig := range applied {
+			log.Printf("Applied %04d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Print("Schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("Invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(steps)
+		for _, mig := range reverted {
+			log.Printf("Reverted %04d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("Can't get migration status: %v", err)
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, state)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q", args[0])
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// NameEnrichment закэшированный ответ провайдера обогащения для имени,
+// Country пустая для глобального ответа
+type NameEnrichment struct {
+	Provider  string `gorm:"primaryKey"`
+	Name      string `gorm:"primaryKey"`
+	Country   string `gorm:"primaryKey;size:2"`
+	Payload   string
+	UpdatedAt time.Time
+}
+
+func (NameEnrichment) TableName() string {
+	return "name_enrichment"
+}
@@ -0,0 +1,47 @@
+package models
+
+import "future_today/internal/filterexpr"
+
+// PersonFilterFields поля персоны, доступные в выражении фильтра. Служебные
+// колонки (search_name, country_hint, is_active, enrichment_pending,
+// deleted_at) в фильтр не попадают
+var PersonFilterFields = filterexpr.FieldsOf(Person{}).Only(
+	"id", "created_at", "updated_at",
+	"name", "surname", "patronymic",
+	"age", "gender", "nationality", "enriched_at",
+	"age_count", "gender_probability", "nationality_probability",
+)
+
+// PersonFilter фильтры списка персон, nil - фильтр не задан
+type PersonFilter struct {
+	Name                      *string  `json:"name,omitempty"`
+	Surname                   *string  `json:"surname,omitempty"`
+	Patronymic                *string  `json:"patronymic,omitempty"`
+	Gender                    *string  `json:"gender,omitempty"`
+	Nationality               *string  `json:"nationality,omitempty"`
+	MinAge                    *int     `json:"min_age,omitempty"`
+	MaxAge                    *int     `json:"max_age,omitempty"`
+	MinGenderProbability      *float64 `json:"min_gender_probability,omitempty"`
+	MinNationalityProbability *float64 `json:"min_nationality_probability,omitempty"`
+	// Expr разобранный параметр filter, добавляется к остальным фильтрам через AND
+	Expr filterexpr.Expr `json:"-"`
+
+	Limit  int         `json:"-"`
+	Offset int         `json:"-"`
+	Sort   []SortField `json:"-"`
+	// Cursor непрозрачный курсор keyset-пагинации, если задан, Offset не используется
+	Cursor string `json:"-"`
+}
+
+// IsEmpty ни одно условие фильтра не задано, под него попадают все персоны
+func (f *PersonFilter) IsEmpty() bool {
+	return f.Name == nil && f.Surname == nil && f.Patronymic == nil && f.Gender == nil && f.Nationality == nil &&
+		f.MinAge == nil && f.MaxAge == nil && f.MinGenderProbability == nil && f.MinNationalityProbability == nil &&
+		f.Expr == nil
+}
+
+// SortField поле сортировки списка, Desc - по убыванию
+type SortField struct {
+	Field string
+	Desc  bool
+}
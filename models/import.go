@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+type ImportStatus string
+
+const (
+	ImportRunning ImportStatus = "running"
+	ImportDone    ImportStatus = "done"
+	ImportFailed  ImportStatus = "failed"
+)
+
+// ImportJob загрузка персон из файла. Строки с ошибками не мешают
+// остальным, Failed - их число. Status failed - файл не дочитан до конца,
+// строки до обрыва сохранены
+type ImportJob struct {
+	ID         uint         `gorm:"primaryKey"`
+	Status     ImportStatus `gorm:"size:16"`
+	Format     string       `gorm:"size:16"`
+	Filename   string
+	Total      int
+	Created    int
+	Failed     int
+	Error      string
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// ImportRow результат одной строки файла: PersonID созданной персоны
+// и задачи обогащения или Error. Line - номер строки в файле с 1
+type ImportRow struct {
+	ID              uint `gorm:"primaryKey"`
+	ImportJobID     uint `gorm:"index"`
+	Line            int
+	PersonID        *uint
+	EnrichmentJobID *uint
+	Error           string
+}
+
+type ImportJobResponse struct {
+	ID         uint         `json:"id"`
+	Status     ImportStatus `json:"status"`
+	Format     string       `json:"format"`
+	Filename   string       `json:"filename,omitempty"`
+	Total      int          `json:"total"`
+	Created    int          `json:"created"`
+	Failed     int          `json:"failed"`
+	Error      string       `json:"error,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	// Errors первые строки с ошибками, все строки - в Report
+	Errors []ImportRowResponse `json:"errors,omitempty"`
+	Report string              `json:"report"`
+}
+
+type ImportRowResponse struct {
+	Line            int    `json:"line"`
+	PersonID        *uint  `json:"person_id,omitempty"`
+	EnrichmentJobID *uint  `json:"enrichment_job_id,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
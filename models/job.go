@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// EnrichmentJob задача фонового обогащения персоны. Пока задача в работе,
+// LockedUntil продлевает аренду воркера, просроченная аренда значит,
+// что воркер упал и задачу можно взять снова
+type EnrichmentJob struct {
+	ID       uint      `gorm:"primaryKey"`
+	PersonID uint      `gorm:"index"`
+	Status   JobStatus `gorm:"index;size:16"`
+	Attempts int
+	// Force перезаписать и заданные вручную поля
+	Force       bool
+	Error       string
+	LockedUntil *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type JobResponse struct {
+	ID        uint      `json:"id"`
+	PersonID  uint      `json:"person_id"`
+	Status    JobStatus `json:"status"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreatePersonAsyncResponse struct {
+	JobID  uint           `json:"job_id"`
+	Person PersonResponse `json:"person"`
+}
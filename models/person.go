@@ -1,14 +1,84 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Person struct {
 	gorm.Model
-	Name        string `gorm:"index"`
-	Surname     string `gorm:"index"`
-	Patronymic  string
+	Name       string `gorm:"index"`
+	Surname    string `gorm:"index"`
+	Patronymic string
+	// имя, фамилия и отчество латиницей для поиска, заполняет хранилище
+	SearchName string
+	// страна, к которой привязывать возраст и пол при обогащении
+	CountryHint string `gorm:"size:2"`
 	Age         int
 	Nationality string
 	Gender      string
 	IsActive    bool `gorm:"default:true"`
+	// часть полей не заполнена из-за недоступного провайдера
+	EnrichmentPending bool       `gorm:"index"`
+	EnrichedAt        *time.Time `gorm:"index"`
+
+	// уверенность обогащения
+	AgeCount               int
+	GenderProbability      float64
+	NationalityProbability float64
+	Countries              []PersonCountry `gorm:"constraint:OnDelete:CASCADE"`
+
+	Provenance []FieldProvenance `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// PersonCountry страна из распределения национальностей, Rank с нуля
+type PersonCountry struct {
+	ID          uint   `gorm:"primaryKey"`
+	PersonID    uint   `gorm:"index"`
+	CountryID   string `gorm:"size:2"`
+	Probability float64
+	Rank        int
+}
+
+const (
+	SourceManual     = "manual"
+	SourceEnrichment = "enrichment"
+)
+
+// FieldProvenance откуда взялось значение поля персоны. Confidence
+// пустой, если провайдер не отдает вероятность
+type FieldProvenance struct {
+	ID         uint   `gorm:"primaryKey"`
+	PersonID   uint   `gorm:"uniqueIndex:idx_provenance_person_field"`
+	Field      string `gorm:"uniqueIndex:idx_provenance_person_field;size:32"`
+	Source     string `gorm:"size:16"`
+	Provider   string
+	Confidence *float64
+	UpdatedAt  time.Time
+}
+
+func (p *Person) FieldProvenance(field string) *FieldProvenance {
+	for i := range p.Provenance {
+		if p.Provenance[i].Field == field {
+			return &p.Provenance[i]
+		}
+	}
+	return nil
+}
+
+// IsManual значение поля задано вручную
+func (p *Person) IsManual(field string) bool {
+	fp := p.FieldProvenance(field)
+	return fp != nil && fp.Source == SourceManual
+}
+
+func (p *Person) SetProvenance(fp FieldProvenance) {
+	fp.PersonID = p.ID
+	if cur := p.FieldProvenance(fp.Field); cur != nil {
+		fp.ID = cur.ID
+		*cur = fp
+		return
+	}
+	p.Provenance = append(p.Provenance, fp)
 }
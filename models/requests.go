@@ -1,9 +1,22 @@
 package models
 
+import (
+	"strings"
+	"time"
+)
+
 type CreatePersonRequest struct {
 	Name       string `json:"name" binding:"required"`
 	Surname    string `json:"surname" binding:"required"`
 	Patronymic string `json:"patronymic"`
+	// ISO 3166-1 alpha-2, уточняет возраст и пол
+	CountryHint string `json:"country_hint" binding:"omitempty,iso3166_1_alpha2"`
+}
+
+// Normalize приводит код страны к верхнему регистру, вызывается до проверки:
+// iso3166_1_alpha2 не принимает "ru"
+func (r *CreatePersonRequest) Normalize() {
+	r.CountryHint = strings.ToUpper(strings.TrimSpace(r.CountryHint))
 }
 
 type UpdatePersonRequest struct {
@@ -15,13 +28,93 @@ type UpdatePersonRequest struct {
 	Nationality *string `json:"nationality,omitempty"`
 }
 
+// PersonListResponse страница списка персон. Next - ссылка на следующую
+// страницу, пустая на последней. NextCursor продолжает список
+// keyset-пагинацией, в режиме курсора Offset не используется
+type PersonListResponse struct {
+	Items      []PersonResponse `json:"items"`
+	Total      int64            `json:"total"`
+	Limit      int              `json:"limit"`
+	Offset     int              `json:"offset"`
+	Next       string           `json:"next,omitempty"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// PersonSearchResponse результаты поиска по убыванию релевантности
+type PersonSearchResponse struct {
+	Query string            `json:"query"`
+	Items []PersonSearchHit `json:"items"`
+}
+
+// PersonSearchHit найденная персона. Highlight - поля name, surname,
+// patronymic с совпавшими словами в <em>, только поля с совпадениями.
+// Слово совпадает, если похоже на слово запроса по триграммам; postgres
+// может найти персону по похожести всего текста, тогда Highlight нет
+type PersonSearchHit struct {
+	PersonResponse
+	Score     float64           `json:"score"`
+	Highlight map[string]string `json:"highlight,omitempty"`
+}
+
 type PersonResponse struct {
 	ID          uint   `json:"id"`
 	Name        string `json:"name"`
 	Surname     string `json:"surname"`
 	Patronymic  string `json:"patronymic,omitempty"`
+	CountryHint string `json:"country_hint,omitempty"`
 	Age         int    `json:"age,omitempty"`
 	Gender      string `json:"gender,omitempty"`
 	Nationality string `json:"nationality,omitempty"`
 	IsActive    bool   `json:"is_active"`
+
+	EnrichmentPending bool       `json:"enrichment_pending,omitempty"`
+	EnrichedAt        *time.Time `json:"enriched_at,omitempty"`
+
+	EnrichmentDetails *EnrichmentDetails `json:"enrichment_details,omitempty"`
+
+	Provenance map[string]ProvenanceResponse `json:"provenance,omitempty"`
+}
+
+type ProvenanceResponse struct {
+	Source     string    `json:"source"`
+	Provider   string    `json:"provider,omitempty"`
+	Confidence *float64  `json:"confidence,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// EnrichmentDetails возвращается при ?include=enrichment_details
+type EnrichmentDetails struct {
+	AgeCount               int                  `json:"age_count"`
+	GenderProbability      float64              `json:"gender_probability"`
+	NationalityProbability float64              `json:"nationality_probability"`
+	Countries              []CountryProbability `json:"countries"`
+}
+
+type CountryProbability struct {
+	CountryID   string  `json:"country_id"`
+	Probability float64 `json:"probability"`
+}
+
+type BulkEnrichResponse struct {
+	Queued int    `json:"queued"`
+	JobIDs []uint `json:"job_ids"`
+}
+
+// EnrichmentPreviewResponse что обогащение вернет для имени, без сохранения
+type EnrichmentPreviewResponse struct {
+	Name                   string               `json:"name"`
+	NormalizedName         string               `json:"normalized_name"`
+	Age                    int                  `json:"age"`
+	AgeCount               int                  `json:"age_count"`
+	Gender                 string               `json:"gender"`
+	GenderProbability      float64              `json:"gender_probability"`
+	Nationality            string               `json:"nationality"`
+	NationalityProbability float64              `json:"nationality_probability"`
+	Countries              []CountryProbability `json:"countries"`
+	// страна, к которой привязаны возраст и пол, пустая - глобально
+	LocalizedTo string `json:"localized_to,omitempty"`
+	// провайдер, ответивший за поле
+	Sources map[string]string `json:"sources"`
+	// поля, провайдеры которых сейчас недоступны
+	Missing []string `json:"missing,omitempty"`
 }
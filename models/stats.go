@@ -0,0 +1,46 @@
+package models
+
+// Группировки статистики персон
+const (
+	GroupByGender      = "gender"
+	GroupByNationality = "nationality"
+	GroupByAge         = "age"
+)
+
+// PersonStatsQuery группировка статистики. AgeBucket - ширина корзины
+// возраста в годах, используется при группировке по age
+type PersonStatsQuery struct {
+	GroupBy   []string
+	AgeBucket int
+}
+
+// PersonStats агрегаты одной группы. Поля группировки nil, если по ним
+// не группировали или значение неизвестно. Возраст 0 (не обогащен)
+// в агрегаты возраста не входит
+type PersonStats struct {
+	Gender      *string
+	Nationality *string
+	AgeFrom     *int
+	Count       int64
+	AvgAge      *float64
+	MinAge      *int
+	MaxAge      *int
+}
+
+// PersonStatsResponse статистика по персонам под фильтром
+type PersonStatsResponse struct {
+	GroupBy   []string           `json:"group_by"`
+	AgeBucket int                `json:"age_bucket,omitempty"`
+	Total     int64              `json:"total"`
+	Groups    []PersonStatsGroup `json:"groups"`
+}
+
+// PersonStatsGroup значения группировки (age - корзина "from-to") и
+// запрошенные агрегаты
+type PersonStatsGroup struct {
+	Group  map[string]any `json:"group"`
+	Count  *int64         `json:"count,omitempty"`
+	AvgAge *float64       `json:"avg_age,omitempty"`
+	MinAge *int           `json:"min_age,omitempty"`
+	MaxAge *int           `json:"max_age,omitempty"`
+}
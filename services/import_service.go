@@ -0,0 +1,119 @@
+package person_service
+
+import (
+	"errors"
+	"fmt"
+	"future_today/internal/importer"
+	"future_today/internal/storage"
+	"future_today/models"
+	"io"
+	"time"
+)
+
+// importErrorsLimit сколько строк с ошибками вернуть в ответе на загрузку,
+// остальные - в отчете
+const importErrorsLimit = 100
+
+// ImportService загрузка персон из файлов. Персоны сохраняются пачками
+// вместе с задачами обогащения, само обогащение делают воркеры очереди:
+// они берут задачи пачками и не запрашивают одно имя дважды
+type ImportService struct {
+	imports   storage.ImportRepository
+	batchSize int
+}
+
+func NewImportService(imports storage.ImportRepository, batchSize int) *ImportService {
+	return &ImportService{imports: imports, batchSize: max(batchSize, 1)}
+}
+
+// Import читает файл формата format и создает персоны из строк, прошедших
+// проверку. Ошибка формата файла (заголовок CSV) возвращается до создания
+// загрузки. Если файл оборвался, загрузка завершается со статусом failed,
+// строки до обрыва сохраняются. Возвращает загрузку и первые строки с ошибками
+func (s *ImportService) Import(format, filename string, r io.Reader) (*models.ImportJob, []models.ImportRow, error) {
+	reader, err := importer.NewReader(format, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	job := &models.ImportJob{Status: models.ImportRunning, Format: format, Filename: filename}
+	if err := s.imports.CreateImport(job); err != nil {
+		return nil, nil, err
+	}
+
+	var failed []models.ImportRow
+	batch := make([]storage.ImportItem, 0, s.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.imports.ImportBatch(job.ID, batch); err != nil {
+			return err
+		}
+		for _, item := range batch {
+			job.Total++
+			if item.Person != nil {
+				job.Created++
+				continue
+			}
+			job.Failed++
+			if len(failed) < importErrorsLimit {
+				failed = append(failed, item.Row)
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	var readErr, saveErr error
+	for {
+		row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		item := storage.ImportItem{Row: models.ImportRow{Line: row.Line}}
+		if row.Err != nil {
+			item.Row.Error = row.Err.Error()
+		} else {
+			item.Person = newPerson(&row.Request)
+		}
+		if batch = append(batch, item); len(batch) == s.batchSize {
+			if saveErr = flush(); saveErr != nil {
+				break
+			}
+		}
+	}
+	// строки, прочитанные до обрыва файла, тоже сохраняются
+	if saveErr == nil {
+		saveErr = flush()
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = models.ImportDone
+	switch {
+	case saveErr != nil:
+		job.Status, job.Error = models.ImportFailed, fmt.Sprintf("error saving rows: %v", saveErr)
+	case readErr != nil:
+		job.Status, job.Error = models.ImportFailed, fmt.Sprintf("error reading file: %v", readErr)
+	}
+	if err := s.imports.UpdateImport(job); err != nil {
+		return nil, nil, err
+	}
+	return job, failed, saveErr
+}
+
+func (s *ImportService) GetImport(id uint) (*models.ImportJob, error) {
+	return s.imports.GetImport(id)
+}
+
+// ImportReport передает fn все строки отчета загрузки по порядку
+func (s *ImportService) ImportReport(id uint, fn func(row *models.ImportRow) error) error {
+	if _, err := s.imports.GetImport(id); err != nil {
+		return err
+	}
+	return s.imports.EachImportRow(id, fn)
+}
@@ -0,0 +1,104 @@
+package person_service
+
+import (
+	"errors"
+	"future_today/internal/importer"
+	"future_today/internal/storage"
+	"future_today/models"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// importReport строки отчета загрузки и имена созданных персон по порядку
+func importReport(t *testing.T, s *ImportService, repo storage.PersonRepository, id uint) ([]models.ImportRow, []string) {
+	t.Helper()
+	var rows []models.ImportRow
+	var names []string
+	err := s.ImportReport(id, func(row *models.ImportRow) error {
+		rows = append(rows, *row)
+		if row.PersonID != nil {
+			p, err := repo.GetByID(*row.PersonID)
+			if err != nil {
+				return err
+			}
+			names = append(names, p.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ImportReport: %v", err)
+	}
+	return rows, names
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	const file = "name,surname,country_hint\n" +
+		"Anna,Ivanova,ru\n" +
+		"Boris,,\n" +
+		"Vera,Orlova,\n" +
+		"Gleb,Orlov,XX\n" +
+		"Ivan,Petrov,\n"
+	repo, _, imports := storage.NewMemoryStorage()
+	s := NewImportService(imports, 2)
+
+	job, failed, err := s.Import(importer.CSV, "persons.csv", strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if job.Status != models.ImportDone || job.Total != 5 || job.Created != 3 || job.Failed != 2 {
+		t.Errorf("job = %+v", job)
+	}
+	if len(failed) != 2 || failed[0].Line != 3 || failed[1].Line != 5 {
+		t.Errorf("failed rows = %+v, want lines 3 and 5", failed)
+	}
+	rows, names := importReport(t, s, repo, job.ID)
+	if len(rows) != 5 || strings.Join(names, ",") != "Anna,Vera,Ivan" {
+		t.Errorf("%d report rows, persons %v", len(rows), names)
+	}
+	if p, _ := repo.GetByID(*rows[0].PersonID); p.CountryHint != "RU" || !p.IsActive {
+		t.Errorf("anna = %+v", p)
+	}
+}
+
+// TestImportBrokenFile строки до обрыва файла сохраняются, даже если не
+// набрали полной пачки, загрузка завершается со статусом failed
+func TestImportBrokenFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		file   io.Reader
+	}{
+		{"csv connection reset", importer.CSV, io.MultiReader(
+			strings.NewReader("name,surname\nAnna,Ivanova\nBoris,Petrov\nVera,Orlova\n"),
+			iotest.ErrReader(errors.New("connection reset")))},
+		{"ndjson line too long", importer.NDJSON, strings.NewReader(
+			`{"name":"Anna","surname":"Ivanova"}` + "\n" + `{"name":"Boris","surname":"Petrov"}` + "\n" +
+				`{"name":"Vera","surname":"Orlova"}` + "\n" + strings.Repeat("x", 2<<20) + "\n" +
+				`{"name":"Gleb","surname":"Orlov"}` + "\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, _, imports := storage.NewMemoryStorage()
+			s := NewImportService(imports, 2)
+			job, _, err := s.Import(tt.format, "persons", tt.file)
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if job.Status != models.ImportFailed || !strings.Contains(job.Error, "error reading file") {
+				t.Errorf("status = %s, error = %q, want failed with a read error", job.Status, job.Error)
+			}
+			if job.Total != 3 || job.Created != 3 {
+				t.Errorf("total = %d, created = %d, want 3 rows before the break", job.Total, job.Created)
+			}
+			stored, _ := s.GetImport(job.ID)
+			if stored.Status != models.ImportFailed || stored.FinishedAt == nil {
+				t.Errorf("stored job = %+v", stored)
+			}
+			if _, names := importReport(t, s, repo, job.ID); strings.Join(names, ",") != "Anna,Boris,Vera" {
+				t.Errorf("persons = %v, want Anna,Boris,Vera", names)
+			}
+		})
+	}
+}
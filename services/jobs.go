@@ -0,0 +1,98 @@
+package person_service
+
+import (
+	"context"
+	"future_today/internal/storage"
+	"future_today/models"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EnrichmentWorkers пул воркеров, разбирающих очередь задач обогащения
+type EnrichmentWorkers struct {
+	service     *PersonService
+	queue       storage.JobRepository
+	logger      *logrus.Logger
+	workers     int
+	batch       int
+	poll        time.Duration
+	lease       time.Duration
+	maxAttempts int
+}
+
+// NewEnrichmentWorkers, batch - сколько задач воркер берет за раз,
+// имена пачки обогащаются пакетными запросами
+func NewEnrichmentWorkers(service *PersonService, queue storage.JobRepository, logger *logrus.Logger,
+	workers, batch int, poll, lease time.Duration, maxAttempts int) *EnrichmentWorkers {
+	return &EnrichmentWorkers{
+		service:     service,
+		queue:       queue,
+		logger:      logger,
+		workers:     workers,
+		batch:       batch,
+		poll:        poll,
+		lease:       lease,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Run запускает воркеры и ждет их завершения после отмены ctx
+func (w *EnrichmentWorkers) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *EnrichmentWorkers) loop(ctx context.Context) {
+	for ctx.Err() == nil {
+		jobs, err := w.queue.Claim(w.lease, w.batch)
+		if err != nil {
+			w.logger.Errorf("Error claiming enrichment jobs: %v", err)
+		}
+		if len(jobs) == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.poll):
+			}
+			continue
+		}
+		w.process(ctx, jobs)
+	}
+}
+
+func (w *EnrichmentWorkers) process(ctx context.Context, jobs []models.EnrichmentJob) {
+	jobCtx, cancel := context.WithTimeout(ctx, w.lease)
+	defer cancel()
+
+	ids := make([]uint, len(jobs))
+	force := make(map[uint]bool, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.PersonID
+		force[job.PersonID] = force[job.PersonID] || job.Force
+	}
+	errs := w.service.enrichPersons(jobCtx, ids, force)
+
+	for i := range jobs {
+		job := &jobs[i]
+		if err := errs[job.PersonID]; err != nil {
+			w.logger.Errorf("Error processing enrichment job %d: %v", job.ID, err)
+			if err := w.queue.Fail(job, err, w.maxAttempts); err != nil {
+				w.logger.Errorf("Error saving enrichment job %d: %v", job.ID, err)
+			}
+			continue
+		}
+		if err := w.queue.Complete(job); err != nil {
+			w.logger.Errorf("Error saving enrichment job %d: %v", job.ID, err)
+			continue
+		}
+		w.logger.Infof("Enrichment job %d done", job.ID)
+	}
+}
@@ -1,39 +1,47 @@
 package person_service
 
 import (
+	"context"
 	"fmt"
 	"future_today/internal/addition"
+	"future_today/internal/cerrors"
 	"future_today/internal/storage"
 	"future_today/models"
+	"time"
 )
 
 type PersonService struct {
-	add *addition.Addition
-	orm *storage.OrmRequestManager
+	add   addition.Enricher
+	repo  storage.PersonRepository
+	queue storage.JobRepository
 }
 
-func NewPersonService(add *addition.Addition, orm *storage.OrmRequestManager) *PersonService {
-	return &PersonService{add: add, orm: orm}
+func NewPersonService(add addition.Enricher, repo storage.PersonRepository, queue storage.JobRepository) *PersonService {
+	return &PersonService{add: add, repo: repo, queue: queue}
 }
 
-func (s *PersonService) CreatePerson(req *models.CreatePersonRequest) (*models.Person, error) {
-
-	age, gender, nation, err := s.add.GetAdditionAsync(req.Name)
-	if err != nil {
-		return nil, fmt.Errorf("error adding person data: %v", err)
-	}
-
-	person := &models.Person{
+// newPerson активная персона из запроса на создание, без обогащения
+func newPerson(req *models.CreatePersonRequest) *models.Person {
+	return &models.Person{
 		Name:        req.Name,
 		Surname:     req.Surname,
 		Patronymic:  req.Patronymic,
-		Age:         age,
-		Gender:      gender,
-		Nationality: nation,
+		CountryHint: req.CountryHint,
 		IsActive:    true,
 	}
+}
+
+func (s *PersonService) CreatePerson(ctx context.Context, req *models.CreatePersonRequest) (*models.Person, error) {
+
+	person := newPerson(req)
+	res, err := s.add.Enrich(ctx, enrichQuery(person))
+	if err != nil {
+		return nil, fmt.Errorf("error adding person data: %w", err)
+	}
 
-	err = s.orm.Create(person)
+	applyEnrichment(person, res, false)
+
+	err = s.repo.Create(person)
 	if err != nil {
 		return nil, err
 	}
@@ -41,33 +49,203 @@ func (s *PersonService) CreatePerson(req *models.CreatePersonRequest) (*models.P
 	return person, nil
 }
 
+// CreatePersonAsync сохраняет персону без обогащения и ставит задачу в очередь
+func (s *PersonService) CreatePersonAsync(req *models.CreatePersonRequest) (*models.Person, *models.EnrichmentJob, error) {
+	person := newPerson(req)
+	job, err := s.queue.EnqueueWithPerson(person)
+	if err != nil {
+		return nil, nil, err
+	}
+	return person, job, nil
+}
+
+func (s *PersonService) GetJob(id uint) (*models.EnrichmentJob, error) {
+	return s.queue.GetJob(id)
+}
+
+// ReEnrichPerson заново обогащает персону. Поля, заданные вручную,
+// перезаписываются только при force
+func (s *PersonService) ReEnrichPerson(ctx context.Context, id uint, force bool) (*models.Person, error) {
+	person, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.reEnrich(ctx, person, force); err != nil {
+		return nil, err
+	}
+	return person, nil
+}
+
+// enrichPersonsLimit сколько персон можно поставить в очередь одним запросом
+const enrichPersonsLimit = 10000
+
+// EnrichPersons ставит в очередь переобогащение всех персон под фильтром.
+// Пустой фильтр допускается только с all, больше enrichPersonsLimit
+// персон - cerrors.ErrTooManyPersons, в очередь ничего не ставится
+func (s *PersonService) EnrichPersons(filter *models.PersonFilter, all, force bool) ([]models.EnrichmentJob, error) {
+	if filter.IsEmpty() && !all {
+		return nil, cerrors.ErrFilterRequired
+	}
+	n, err := s.repo.Count(filter)
+	if err != nil {
+		return nil, err
+	}
+	if n > enrichPersonsLimit {
+		return nil, fmt.Errorf("%w: %d match the filter, at most %d per request", cerrors.ErrTooManyPersons, n, enrichPersonsLimit)
+	}
+	ids, err := s.repo.GetIDs(filter)
+	if err != nil {
+		return nil, err
+	}
+	return s.queue.Enqueue(ids, force)
+}
+
+// enrichPersons обогащает сохраненных персон пакетными запросами.
+// Ошибки возвращаются по id, персоны без ошибки сохранены
+func (s *PersonService) enrichPersons(ctx context.Context, ids []uint, force map[uint]bool) map[uint]error {
+	errs := make(map[uint]error, len(ids))
+	persons, err := s.repo.GetByIDs(ids)
+	if err != nil {
+		for _, id := range ids {
+			errs[id] = err
+		}
+		return errs
+	}
+	found := make(map[uint]bool, len(persons))
+	for _, person := range persons {
+		found[person.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			errs[id] = cerrors.ErrNotFound
+		}
+	}
+
+	ptrs := make([]*models.Person, len(persons))
+	for i := range persons {
+		ptrs[i] = &persons[i]
+	}
+	for id, err := range s.reEnrichBatch(ctx, ptrs, force) {
+		errs[id] = err
+	}
+	return errs
+}
+
+func (s *PersonService) reEnrich(ctx context.Context, person *models.Person, force bool) error {
+	res, err := s.add.Enrich(ctx, enrichQuery(person))
+	if err != nil {
+		return fmt.Errorf("error adding person data: %w", err)
+	}
+	return s.saveEnrichment(person, res, force)
+}
+
+// reEnrichBatch как reEnrich для нескольких персон одним пакетом
+func (s *PersonService) reEnrichBatch(ctx context.Context, persons []*models.Person, force map[uint]bool) map[uint]error {
+	errs := make(map[uint]error, len(persons))
+	queries := make([]addition.Query, len(persons))
+	for i, person := range persons {
+		queries[i] = enrichQuery(person)
+	}
+	results, err := s.add.EnrichBatch(ctx, queries)
+	if err != nil {
+		err = fmt.Errorf("error adding person data: %w", err)
+		for _, person := range persons {
+			errs[person.ID] = err
+		}
+		return errs
+	}
+	for _, person := range persons {
+		if err := s.saveEnrichment(person, results[enrichQuery(person)], force[person.ID]); err != nil {
+			errs[person.ID] = err
+		}
+	}
+	return errs
+}
+
+func (s *PersonService) saveEnrichment(person *models.Person, res *addition.Result, force bool) error {
+	if applyEnrichment(person, res, force) {
+		return s.repo.UpdateWithCountries(person)
+	}
+	return s.repo.Update(person)
+}
+
 func (s *PersonService) GetPerson(id uint) (*models.Person, error) {
-	person, err := s.orm.GetByID(id)
+	person, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 	return person, nil
 }
 
-func (s *PersonService) GetAllPersons(
-	limit, offset int,
-	name, surname, patronymic *string,
-	minAge, maxAge *int,
-	gender, nationality *string,
-) ([]models.Person, error) {
-	persons, err := s.orm.GetAll(name, surname, patronymic, gender, nationality, minAge, maxAge, limit, offset)
+// PersonPage страница списка. NextCursor пустой, если страница последняя
+// или порядок не поддерживает курсоры
+type PersonPage struct {
+	Persons    []models.Person
+	Total      int64
+	NextCursor string
+	// HasMore после страницы есть еще персоны
+	HasMore bool
+}
+
+// GetAllPersons страница персон и общее число персон под фильтром
+func (s *PersonService) GetAllPersons(filter *models.PersonFilter) (*PersonPage, error) {
+	// на одну персону больше страницы: по ней видно, есть ли следующая
+	query := *filter
+	if query.Limit > 0 {
+		query.Limit++
+	}
+	persons, err := s.repo.GetAll(&query)
 	if err != nil {
 		return nil, err
 	}
-	return persons, nil
+	total, err := s.repo.Count(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &PersonPage{Persons: persons, Total: total}
+	if filter.Limit > 0 && len(persons) > filter.Limit {
+		page.Persons = persons[:filter.Limit]
+		page.HasMore = true
+		page.NextCursor = storage.EncodeCursor(filter.Sort, &page.Persons[filter.Limit-1])
+	}
+	return page, nil
+}
+
+// ExportPersons передает fn персон под фильтром по одной
+func (s *PersonService) ExportPersons(filter *models.PersonFilter, fn func(person *models.Person) error) error {
+	return s.repo.Each(filter, fn)
 }
 
+// PersonStats статистика по персонам под фильтром
+func (s *PersonService) PersonStats(filter *models.PersonFilter, query models.PersonStatsQuery) ([]models.PersonStats, error) {
+	return s.repo.Stats(filter, query)
+}
+
+// SearchPersons до limit персон, похожих на запрос, по убыванию релевантности
+func (s *PersonService) SearchPersons(query string, limit int) ([]storage.SearchHit, error) {
+	return s.repo.Search(query, limit)
+}
+
+// UpdatePerson читает и сохраняет персону в одной транзакции
 func (s *PersonService) UpdatePerson(id uint, upd *models.UpdatePersonRequest) (*models.Person, error) {
-	person, err := s.orm.GetByID(id)
+	var person *models.Person
+	err := s.repo.Transaction(func(repo storage.PersonRepository) error {
+		var err error
+		person, err = repo.GetByID(id)
+		if err != nil {
+			return err
+		}
+		applyUpdate(person, upd)
+		return repo.Update(person)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return person, nil
+}
 
+func applyUpdate(person *models.Person, upd *models.UpdatePersonRequest) {
 	if upd.Name != nil {
 		person.Name = *upd.Name
 	}
@@ -77,28 +255,86 @@ func (s *PersonService) UpdatePerson(id uint, upd *models.UpdatePersonRequest) (
 	if upd.Patronymic != nil {
 		person.Patronymic = *upd.Patronymic
 	}
+	now := time.Now()
+	manual := models.FieldProvenance{Source: models.SourceManual, UpdatedAt: now}
 	if upd.Age != nil {
 		person.Age = *upd.Age
+		manual.Field = addition.FieldAge
+		person.SetProvenance(manual)
 	}
 	if upd.Gender != nil {
 		person.Gender = *upd.Gender
+		manual.Field = addition.FieldGender
+		person.SetProvenance(manual)
 	}
 	if upd.Nationality != nil {
 		person.Nationality = *upd.Nationality
+		manual.Field = addition.FieldNationality
+		person.SetProvenance(manual)
 	}
-
-	err = s.orm.Update(person)
-	if err != nil {
-		return nil, err
-	}
-
-	return person, nil
 }
 
 func (s *PersonService) DeletePerson(id uint) error {
-	err := s.orm.Delete(id)
+	err := s.repo.Delete(id)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func enrichQuery(person *models.Person) addition.Query {
+	return addition.Query{Name: person.Name, CountryHint: person.CountryHint}
+}
+
+// applyEnrichment переносит ответ обогащения в персону и отмечает
+// происхождение полей. Поля, не полученные от провайдера, и заданные
+// вручную (если не force) не меняются. EnrichedAt обновляется, только если
+// поменялось хотя бы одно поле. Возвращает true, если поменялось
+// распределение стран
+func applyEnrichment(person *models.Person, res *addition.Result, force bool) bool {
+	now := time.Now()
+	apply := func(field string, confidence *float64) bool {
+		if !res.Has(field) || (person.IsManual(field) && !force) {
+			return false
+		}
+		person.SetProvenance(models.FieldProvenance{
+			Field:      field,
+			Source:     models.SourceEnrichment,
+			Provider:   res.Sources[field],
+			Confidence: confidence,
+			UpdatedAt:  now,
+		})
+		return true
+	}
+
+	applied := false
+	if apply(addition.FieldAge, nil) {
+		applied = true
+		person.Age = res.Age
+		person.AgeCount = res.AgeCount
+	}
+	if apply(addition.FieldGender, &res.GenderProbability) {
+		applied = true
+		person.Gender = res.Gender
+		person.GenderProbability = res.GenderProbability
+	}
+	nationChanged := apply(addition.FieldNationality, &res.NationalityProbability)
+	if nationChanged {
+		person.Nationality = res.Nationality
+		person.NationalityProbability = res.NationalityProbability
+		person.Countries = make([]models.PersonCountry, len(res.Countries))
+		for i, c := range res.Countries {
+			person.Countries[i] = models.PersonCountry{
+				CountryID:   c.CountryID,
+				Probability: c.Probability,
+				Rank:        i,
+			}
+		}
+	}
+	// без единого нового поля персона остается устаревшей для обновления
+	if applied || nationChanged {
+		person.EnrichedAt = &now
+	}
+	person.EnrichmentPending = res.Pending()
+	return nationChanged
+}
@@ -0,0 +1,307 @@
+package person_service
+
+import (
+	"context"
+	"errors"
+	"future_today/internal/addition"
+	"future_today/internal/cerrors"
+	"future_today/internal/storage"
+	"future_today/models"
+	"testing"
+)
+
+// fakeEnricher отвечает заранее заданными результатами по имени
+type fakeEnricher struct {
+	results map[string]*addition.Result
+	err     error
+	calls   int
+}
+
+func (f *fakeEnricher) Enrich(ctx context.Context, q addition.Query) (*addition.Result, error) {
+	results, err := f.EnrichBatch(ctx, []addition.Query{q})
+	if err != nil {
+		return nil, err
+	}
+	return results[q], nil
+}
+
+func (f *fakeEnricher) EnrichBatch(_ context.Context, qs []addition.Query) (map[addition.Query]*addition.Result, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	results := make(map[addition.Query]*addition.Result, len(qs))
+	for _, q := range qs {
+		res, ok := f.results[q.Name]
+		if !ok {
+			res = &addition.Result{Missing: []string{addition.FieldAge, addition.FieldGender, addition.FieldNationality}}
+		}
+		results[q] = res
+	}
+	return results, nil
+}
+
+func annaResult(age int) *addition.Result {
+	return &addition.Result{
+		Age:                    age,
+		AgeCount:               1200,
+		Gender:                 "female",
+		GenderProbability:      0.98,
+		Nationality:            "RU",
+		NationalityProbability: 0.6,
+		Countries: []addition.CountryProbability{
+			{CountryID: "RU", Probability: 0.6},
+			{CountryID: "UA", Probability: 0.2},
+		},
+		Sources: map[string]string{
+			addition.FieldAge:         "agify",
+			addition.FieldGender:      "genderize",
+			addition.FieldNationality: "nationalize",
+		},
+	}
+}
+
+func newTestService(add addition.Enricher) (*PersonService, *storage.MemoryPersonRepository) {
+	repo, queue, _ := storage.NewMemoryStorage()
+	return NewPersonService(add, repo, queue), repo
+}
+
+func createAnna(t *testing.T, s *PersonService) *models.Person {
+	t.Helper()
+	person, err := s.CreatePerson(context.Background(), &models.CreatePersonRequest{Name: "Anna", Surname: "Ivanova", CountryHint: "RU"})
+	if err != nil {
+		t.Fatalf("CreatePerson: %v", err)
+	}
+	return person
+}
+
+func assertProvenance(t *testing.T, person *models.Person, field, source, provider string) {
+	t.Helper()
+	fp := person.FieldProvenance(field)
+	if fp == nil {
+		t.Fatalf("%s has no provenance", field)
+	}
+	if fp.Source != source || fp.Provider != provider {
+		t.Errorf("%s provenance = %s/%s, want %s/%s", field, fp.Source, fp.Provider, source, provider)
+	}
+}
+
+func TestCreatePerson(t *testing.T) {
+	s, repo := newTestService(&fakeEnricher{results: map[string]*addition.Result{"Anna": annaResult(34)}})
+	created := createAnna(t, s)
+
+	person, err := repo.GetByID(created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if person.Age != 34 || person.Gender != "female" || person.Nationality != "RU" || person.CountryHint != "RU" {
+		t.Errorf("saved person = %+v", person)
+	}
+	if len(person.Countries) != 2 || person.Countries[1].CountryID != "UA" || person.Countries[1].Rank != 1 {
+		t.Errorf("countries = %+v", person.Countries)
+	}
+	if person.EnrichmentPending || person.EnrichedAt == nil {
+		t.Errorf("pending = %v, enriched_at = %v", person.EnrichmentPending, person.EnrichedAt)
+	}
+	assertProvenance(t, person, addition.FieldAge, models.SourceEnrichment, "agify")
+	assertProvenance(t, person, addition.FieldGender, models.SourceEnrichment, "genderize")
+	if c := person.FieldProvenance(addition.FieldGender).Confidence; c == nil || *c != 0.98 {
+		t.Errorf("gender confidence = %v, want 0.98", c)
+	}
+}
+
+func TestCreatePersonPending(t *testing.T) {
+	res := annaResult(34)
+	res.Missing = []string{addition.FieldAge}
+	delete(res.Sources, addition.FieldAge)
+	s, repo := newTestService(&fakeEnricher{results: map[string]*addition.Result{"Anna": res}})
+	created := createAnna(t, s)
+
+	person, err := repo.GetByID(created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if !person.EnrichmentPending || person.Age != 0 || person.FieldProvenance(addition.FieldAge) != nil {
+		t.Errorf("pending = %v, age = %d, age provenance = %v", person.EnrichmentPending, person.Age, person.FieldProvenance(addition.FieldAge))
+	}
+	if person.Gender != "female" {
+		t.Errorf("gender = %q, want female", person.Gender)
+	}
+}
+
+func TestCreatePersonEnrichmentError(t *testing.T) {
+	quota := &addition.QuotaError{Provider: "agify"}
+	s, repo := newTestService(&fakeEnricher{err: quota})
+	_, err := s.CreatePerson(context.Background(), &models.CreatePersonRequest{Name: "Anna", Surname: "Ivanova"})
+	if !errors.Is(err, cerrors.ErrQuotaExhausted) {
+		t.Fatalf("CreatePerson error = %v, want quota error", err)
+	}
+	if n, _ := repo.Count(&models.PersonFilter{}); n != 0 {
+		t.Errorf("%d persons saved after failed enrichment", n)
+	}
+}
+
+func TestUpdatePersonProvenance(t *testing.T) {
+	s, repo := newTestService(&fakeEnricher{results: map[string]*addition.Result{"Anna": annaResult(34)}})
+	created := createAnna(t, s)
+
+	age, surname := 50, "Petrova"
+	if _, err := s.UpdatePerson(created.ID, &models.UpdatePersonRequest{Age: &age, Surname: &surname}); err != nil {
+		t.Fatalf("UpdatePerson: %v", err)
+	}
+	person, err := repo.GetByID(created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if person.Age != 50 || person.Surname != "Petrova" {
+		t.Errorf("age = %d, surname = %q", person.Age, person.Surname)
+	}
+	assertProvenance(t, person, addition.FieldAge, models.SourceManual, "")
+	assertProvenance(t, person, addition.FieldGender, models.SourceEnrichment, "genderize")
+	if len(person.Provenance) != 3 {
+		t.Errorf("%d provenance records, want 3", len(person.Provenance))
+	}
+
+	if _, err := s.UpdatePerson(999, &models.UpdatePersonRequest{Age: &age}); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Errorf("UpdatePerson(999) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReEnrichPerson(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		name := "keeps manual fields"
+		if force {
+			name = "force"
+		}
+		t.Run(name, func(t *testing.T) {
+			add := &fakeEnricher{results: map[string]*addition.Result{"Anna": annaResult(34)}}
+			s, repo := newTestService(add)
+			created := createAnna(t, s)
+			age := 50
+			if _, err := s.UpdatePerson(created.ID, &models.UpdatePersonRequest{Age: &age}); err != nil {
+				t.Fatalf("UpdatePerson: %v", err)
+			}
+
+			res := annaResult(36)
+			res.Gender, res.GenderProbability = "male", 0.51
+			add.results["Anna"] = res
+			if _, err := s.ReEnrichPerson(context.Background(), created.ID, force); err != nil {
+				t.Fatalf("ReEnrichPerson: %v", err)
+			}
+			person, err := repo.GetByID(created.ID)
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if person.Gender != "male" {
+				t.Errorf("gender = %q, want male", person.Gender)
+			}
+			if force {
+				if person.Age != 36 {
+					t.Errorf("age = %d, want 36", person.Age)
+				}
+				assertProvenance(t, person, addition.FieldAge, models.SourceEnrichment, "agify")
+			} else {
+				if person.Age != 50 {
+					t.Errorf("age = %d, want manual 50", person.Age)
+				}
+				assertProvenance(t, person, addition.FieldAge, models.SourceManual, "")
+			}
+		})
+	}
+}
+
+func TestReEnrichPersonNotFound(t *testing.T) {
+	add := &fakeEnricher{}
+	s, _ := newTestService(add)
+	if _, err := s.ReEnrichPerson(context.Background(), 999, false); !errors.Is(err, cerrors.ErrNotFound) {
+		t.Errorf("ReEnrichPerson(999) error = %v, want ErrNotFound", err)
+	}
+	if add.calls != 0 {
+		t.Errorf("enricher called %d times for a missing person", add.calls)
+	}
+}
+
+func TestEnrichAllProvidersFailed(t *testing.T) {
+	add := &fakeEnricher{results: map[string]*addition.Result{}}
+	s, repo := newTestService(add)
+	created, err := s.CreatePerson(context.Background(), &models.CreatePersonRequest{Name: "Anna", Surname: "Ivanova"})
+	if err != nil {
+		t.Fatalf("CreatePerson: %v", err)
+	}
+	person, err := repo.GetByID(created.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if !person.EnrichmentPending || person.EnrichedAt != nil || len(person.Provenance) != 0 {
+		t.Errorf("pending = %v, enriched_at = %v, provenance = %v, want pending and not enriched",
+			person.EnrichmentPending, person.EnrichedAt, person.Provenance)
+	}
+
+	// удачное обогащение, затем снова отказ всех провайдеров: время прежнее
+	add.results["Anna"] = annaResult(34)
+	if _, err := s.ReEnrichPerson(context.Background(), created.ID, false); err != nil {
+		t.Fatalf("ReEnrichPerson: %v", err)
+	}
+	enriched, _ := repo.GetByID(created.ID)
+	delete(add.results, "Anna")
+	if _, err := s.ReEnrichPerson(context.Background(), created.ID, false); err != nil {
+		t.Fatalf("ReEnrichPerson: %v", err)
+	}
+	person, _ = repo.GetByID(created.ID)
+	if person.EnrichedAt == nil || !person.EnrichedAt.Equal(*enriched.EnrichedAt) {
+		t.Errorf("enriched_at = %v, want unchanged %v", person.EnrichedAt, enriched.EnrichedAt)
+	}
+	if !person.EnrichmentPending || person.Age != 34 {
+		t.Errorf("pending = %v, age = %d, want pending with previous age", person.EnrichmentPending, person.Age)
+	}
+}
+
+func TestEnrichPersons(t *testing.T) {
+	s, repo := newTestService(&fakeEnricher{})
+	for _, name := range []string{"Anna", "Boris", "Anna"} {
+		if err := repo.Create(&models.Person{Name: name, Surname: "Ivanov", IsActive: true}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	anna := "Anna"
+	tests := []struct {
+		name    string
+		filter  models.PersonFilter
+		all     bool
+		queued  int
+		wantErr error
+	}{
+		{"empty filter", models.PersonFilter{}, false, 0, cerrors.ErrFilterRequired},
+		{"empty filter with all", models.PersonFilter{}, true, 3, nil},
+		{"filter", models.PersonFilter{Name: &anna}, false, 2, nil},
+		{"paging isn't a condition", models.PersonFilter{Limit: 1}, false, 0, cerrors.ErrFilterRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs, err := s.EnrichPersons(&tt.filter, tt.all, false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("EnrichPersons error = %v, want %v", err, tt.wantErr)
+			}
+			if len(jobs) != tt.queued {
+				t.Errorf("%d jobs queued, want %d", len(jobs), tt.queued)
+			}
+		})
+	}
+}
+
+func TestEnrichPersonsLimit(t *testing.T) {
+	s, repo := newTestService(&fakeEnricher{})
+	for range enrichPersonsLimit + 1 {
+		if err := repo.Create(&models.Person{Name: "Anna", Surname: "Ivanova", IsActive: true}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	jobs, err := s.EnrichPersons(&models.PersonFilter{}, true, false)
+	if !errors.Is(err, cerrors.ErrTooManyPersons) || len(jobs) != 0 {
+		t.Fatalf("EnrichPersons = %d jobs, %v, want ErrTooManyPersons", len(jobs), err)
+	}
+	maxAge := 0
+	if _, err := s.EnrichPersons(&models.PersonFilter{MaxAge: &maxAge}, false, false); !errors.Is(err, cerrors.ErrTooManyPersons) {
+		t.Errorf("EnrichPersons with filter error = %v, want ErrTooManyPersons", err)
+	}
+}
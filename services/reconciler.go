@@ -0,0 +1,65 @@
+package person_service
+
+import (
+	"context"
+	"future_today/internal/storage"
+	"future_today/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Reconciler периодически дообогащает записи, сохраненные
+// при разомкнутом предохранителе провайдера
+type Reconciler struct {
+	service  *PersonService
+	repo     storage.PersonRepository
+	logger   *logrus.Logger
+	interval time.Duration
+	batch    int
+}
+
+func NewReconciler(service *PersonService, repo storage.PersonRepository, logger *logrus.Logger, interval time.Duration, batch int) *Reconciler {
+	return &Reconciler{service: service, repo: repo, logger: logger, interval: interval, batch: batch}
+}
+
+// Run работает до отмены ctx
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reconcile(ctx)
+		}
+	}
+}
+
+func (r *Reconciler) reconcile(ctx context.Context) {
+	persons, err := r.repo.GetPendingEnrichment(r.batch)
+	if err != nil {
+		r.logger.Errorf("Error getting pending persons: %v", err)
+		return
+	}
+	if len(persons) == 0 {
+		return
+	}
+	ptrs := make([]*models.Person, len(persons))
+	for i := range persons {
+		ptrs[i] = &persons[i]
+	}
+	errs := r.service.reEnrichBatch(ctx, ptrs, nil)
+	for _, person := range ptrs {
+		if err := errs[person.ID]; err != nil {
+			r.logger.Errorf("Error enriching person %d: %v", person.ID, err)
+			continue
+		}
+		if person.EnrichmentPending {
+			// провайдер еще недоступен, ждем следующего прохода
+			continue
+		}
+		r.logger.Infof("Person %d enrichment reconciled", person.ID)
+	}
+}
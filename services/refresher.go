@@ -0,0 +1,58 @@
+package person_service
+
+import (
+	"context"
+	"future_today/internal/storage"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Refresher периодически ставит в очередь переобогащение записей,
+// обогащенных раньше, чем maxAge назад
+type Refresher struct {
+	repo     storage.PersonRepository
+	queue    storage.JobRepository
+	logger   *logrus.Logger
+	interval time.Duration
+	maxAge   time.Duration
+	batch    int
+}
+
+func NewRefresher(repo storage.PersonRepository, queue storage.JobRepository, logger *logrus.Logger,
+	interval, maxAge time.Duration, batch int) *Refresher {
+	return &Refresher{repo: repo, queue: queue, logger: logger, interval: interval, maxAge: maxAge, batch: batch}
+}
+
+// Run работает до отмены ctx, при maxAge <= 0 сразу выходит
+func (r *Refresher) Run(ctx context.Context) {
+	if r.maxAge <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.refresh()
+		}
+	}
+}
+
+func (r *Refresher) refresh() {
+	ids, err := r.repo.GetStaleIDs(time.Now().Add(-r.maxAge), r.batch)
+	if err != nil {
+		r.logger.Errorf("Error getting stale persons: %v", err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if _, err := r.queue.Enqueue(ids, false); err != nil {
+		r.logger.Errorf("Error queueing stale persons: %v", err)
+		return
+	}
+	r.logger.Infof("Queued re-enrichment of %d stale persons", len(ids))
+}